@@ -3,34 +3,154 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
 )
 
+// alertSeverityRank orders severities from the most to the least severe.
+var alertSeverityRank = map[string]int{
+	"SEVERE":           0,
+	"WARNING":          1,
+	"INFO":             2,
+	"UNKNOWN_SEVERITY": 3,
+}
+
+// alertFilter selects which alerts are shown. Empty fields match every alert.
+type alertFilter struct {
+	Severities []string
+	Effects    []string
+	Causes     []string
+	Routes     []string
+	Stops      []string
+	ActiveNow  bool
+}
+
+// empty tells whether f matches every alert.
+func (f alertFilter) empty() bool {
+	return len(f.Severities) == 0 && len(f.Effects) == 0 && len(f.Causes) == 0 &&
+		len(f.Routes) == 0 && len(f.Stops) == 0 && !f.ActiveNow
+}
+
+func (f alertFilter) match(alert Alert, now time.Time) bool {
+	if len(f.Severities) > 0 && !slices.Contains(f.Severities, alert.AlertSeverityLevel) {
+		return false
+	}
+	if len(f.Effects) > 0 && !slices.Contains(f.Effects, alert.AlertEffect) {
+		return false
+	}
+	if len(f.Causes) > 0 && !slices.Contains(f.Causes, alert.AlertCause) {
+		return false
+	}
+
+	// An alert affecting any of the routes or stops is enough
+	if len(f.Routes) > 0 || len(f.Stops) > 0 {
+		affected := slices.ContainsFunc(alert.AffectedRoutes(), func(route AlertEntity) bool {
+			return slices.ContainsFunc(f.Routes, func(r string) bool { return strings.EqualFold(r, route.ShortName) })
+		}) || slices.ContainsFunc(alert.AffectedStops(), func(stop AlertEntity) bool {
+			return slices.ContainsFunc(f.Stops, func(s string) bool { return strings.EqualFold(s, stop.Code) })
+		})
+		if !affected {
+			return false
+		}
+	}
+
+	if f.ActiveNow {
+		if alert.EffectiveStartDate > now.Unix() {
+			return false
+		}
+		if alert.EffectiveEndDate != 0 && alert.EffectiveEndDate < now.Unix() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func filterAlerts(alerts []Alert, filter alertFilter, now time.Time) []Alert {
+	filtered := []Alert{}
+	for _, alert := range alerts {
+		if filter.match(alert, now) {
+			filtered = append(filtered, alert)
+		}
+	}
+
+	return filtered
+}
+
+// sortAlerts sorts alerts by severity, most severe first, and then by start
+// date.
+func sortAlerts(alerts []Alert) {
+	rank := func(severity string) int {
+		if r, ok := alertSeverityRank[severity]; ok {
+			return r
+		}
+		return len(alertSeverityRank)
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if rank(a.AlertSeverityLevel) != rank(b.AlertSeverityLevel) {
+			return rank(a.AlertSeverityLevel) < rank(b.AlertSeverityLevel)
+		}
+		return a.EffectiveStartDate < b.EffectiveStartDate
+	})
+}
+
+// showAlerts fetches, filters and sorts the alerts and prints them or shows
+// them in the TUI.
+func showAlerts(apikey string, filter alertFilter, tui bool) {
+	data, err := getAllAlerts(apikey)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	data = filterAlerts(data, filter, time.Now())
+	sortAlerts(data)
+
+	if tui {
+		tuiDisplayAlerts(data)
+
+		return
+	}
+
+	printAlerts(data)
+}
+
 func printAlerts(alerts []Alert) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Alert", "Severity", "Date", "Effect", "Link"})
+	t.AppendHeader(table.Row{tr("Alert"), tr("Routes"), tr("Severity"), tr("Date"), tr("Effect"), tr("Link")})
 
 	t.SetStyle(table.StyleRounded)
 
-	fmt.Println(bold("HSL Alerts"))
+	fmt.Println(bold(tr("HSL Alerts")))
 
 	for _, alert := range alerts {
-		link := hyperlink("Link", alert.AlertUrl)
+		link := hyperlink(tr("Link"), alert.AlertUrl)
 		if alert.AlertUrl == "" {
-			link = "No link"
+			link = tr("No link")
+		}
+
+		routes := []string{}
+		for _, route := range alert.AffectedRoutes() {
+			routes = append(routes, hyperlink(routeLabel(route.route(), route.ShortName), route.Url))
 		}
 
 		t.AppendRow(table.Row{
-			alert.AlertHeaderText + "\n",
+			alert.Header(language) + "\n",
+			strings.Join(routes, ", "),
 			alert.AlertSeverityLevel,
 			fmt.Sprintf("%v-%v",
-				time.Unix(alert.EffectiveStartDate, 0).Format("15:04 01.02"),
-				time.Unix(alert.EffectiveEndDate, 0).Format("15:04 01.02")),
+				time.Unix(alert.EffectiveStartDate, 0).In(hsltime.Helsinki).Format("15:04 01.02"),
+				time.Unix(alert.EffectiveEndDate, 0).In(hsltime.Helsinki).Format("15:04 01.02")),
 			alert.AlertEffect,
 			link,
 		})
@@ -50,13 +170,57 @@ func printAlerts(alerts []Alert) {
 	t.Render()
 
 }
+
+// alertDetails formats everything known about an alert for the TUI detail
+// pane.
+func alertDetails(alert Alert) string {
+	details := fmt.Sprintf("[::b]%v[::-]\n\n%v\n",
+		tview.Escape(alert.Header(language)), tview.Escape(alert.Description(language)))
+
+	routes := []string{}
+	for _, route := range alert.AffectedRoutes() {
+		routes = append(routes, fmt.Sprintf("%v %v", transportModeEmoji(route.Mode), tuiRouteLabel(route.route(), route.ShortName)))
+	}
+	if len(routes) > 0 {
+		details += "\n[::b]" + tr("Routes:") + "[::-] " + strings.Join(routes, ", ") + "\n"
+	}
+
+	stops := []string{}
+	for _, stop := range alert.AffectedStops() {
+		stops = append(stops, fmt.Sprintf("%v (%v)", stop.Name, stop.Code))
+	}
+	if len(stops) > 0 {
+		details += "\n[::b]" + tr("Stops:") + "[::-] " + tview.Escape(strings.Join(stops, ", ")) + "\n"
+	}
+
+	details += fmt.Sprintf("\n[::b]%v[::-] %v  [::b]%v[::-] %v\n", tr("Cause:"), alert.AlertCause, tr("Effect:"), alert.AlertEffect)
+	if alert.AlertUrl != "" {
+		details += "\n" + tview.Escape(alert.AlertUrl) + "\n"
+	}
+
+	return details
+}
+
 func tuiDisplayAlerts(alerts []Alert) {
 	app := tview.NewApplication()
 	table := tview.NewTable().
 		SetBorders(true).
-		SetFixed(1, 0)
+		SetFixed(1, 0).
+		SetSelectable(true, false)
+
+	details := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	details.SetBorder(true).SetTitle(tr("Details"))
+
+	table.SetSelectionChangedFunc(func(row, column int) {
+		details.Clear()
+		if row > 0 && row <= len(alerts) {
+			details.SetText(alertDetails(alerts[row-1])).ScrollToBeginning()
+		}
+	})
 
-	headers := []string{"Alert", "Severity", "Date", "Effect", "Link"}
+	headers := []string{tr("Alert"), tr("Routes"), tr("Severity"), tr("Date"), tr("Effect"), tr("Link")}
 	for i, header := range headers {
 		table.SetCell(0, i, tview.NewTableCell(header).
 			SetTextColor(tview.Styles.SecondaryTextColor).
@@ -65,22 +229,29 @@ func tuiDisplayAlerts(alerts []Alert) {
 	}
 
 	for i, alert := range alerts {
-		link := "No link"
+		link := tr("No link")
 		if alert.AlertUrl != "" {
 			link = alert.AlertUrl
 		}
 
-		table.SetCell(i+1, 0, tview.NewTableCell(alert.AlertHeaderText).
+		routes := []string{}
+		for _, route := range alert.AffectedRoutes() {
+			routes = append(routes, tuiRouteLabel(route.route(), route.ShortName))
+		}
+
+		table.SetCell(i+1, 0, tview.NewTableCell(alert.Header(language)).
 			SetTextColor(tview.Styles.PrimaryTextColor))
-		table.SetCell(i+1, 1, tview.NewTableCell(alert.AlertSeverityLevel).
+		table.SetCell(i+1, 1, tview.NewTableCell(strings.Join(routes, ", ")).
 			SetTextColor(tview.Styles.PrimaryTextColor))
-		table.SetCell(i+1, 2, tview.NewTableCell(fmt.Sprintf("%v-%v",
-			time.Unix(alert.EffectiveStartDate, 0).Format("15:04 01.02"),
-			time.Unix(alert.EffectiveEndDate, 0).Format("15:04 01.02"))).
+		table.SetCell(i+1, 2, tview.NewTableCell(alert.AlertSeverityLevel).
 			SetTextColor(tview.Styles.PrimaryTextColor))
-		table.SetCell(i+1, 3, tview.NewTableCell(alert.AlertEffect).
+		table.SetCell(i+1, 3, tview.NewTableCell(fmt.Sprintf("%v-%v",
+			time.Unix(alert.EffectiveStartDate, 0).In(hsltime.Helsinki).Format("15:04 01.02"),
+			time.Unix(alert.EffectiveEndDate, 0).In(hsltime.Helsinki).Format("15:04 01.02"))).
 			SetTextColor(tview.Styles.PrimaryTextColor))
-		table.SetCell(i+1, 4, tview.NewTableCell(link).
+		table.SetCell(i+1, 4, tview.NewTableCell(alert.AlertEffect).
+			SetTextColor(tview.Styles.PrimaryTextColor))
+		table.SetCell(i+1, 5, tview.NewTableCell(link).
 			SetTextColor(tview.Styles.PrimaryTextColor))
 	}
 
@@ -94,7 +265,17 @@ func tuiDisplayAlerts(alerts []Alert) {
 		return event
 	})
 
-	if err := app.SetRoot(table, true).Run(); err != nil {
+	if len(alerts) > 0 {
+		table.Select(1, 0)
+		details.SetText(alertDetails(alerts[0]))
+	}
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(table, 0, 2, true).
+		AddItem(details, 0, 1, false)
+
+	if err := app.SetRoot(layout, true).Run(); err != nil {
 		panic(err)
 	}
 }
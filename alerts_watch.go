@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// Kinds of alertEvent
+const (
+	alertNew      = "new"
+	alertChanged  = "changed"
+	alertResolved = "resolved"
+)
+
+// alertEvent is a change in the alerts between two polls.
+type alertEvent struct {
+	Kind  string `json:"kind"`
+	Alert Alert  `json:"alert"`
+}
+
+// alertWatchState is what -watch remembers between polls and between runs:
+// the last seen version of every alert, by ID. It has all the alerts, not
+// just the filtered ones, so changing the filter between runs doesn't make
+// alerts look new or resolved.
+type alertWatchState map[string]Alert
+
+func alertStateFilePath() string {
+	return configFilePath("alert-state.json")
+}
+
+// loadAlertWatchState loads the state saved by an earlier run, nil if there is
+// none.
+func loadAlertWatchState(path string) (alertWatchState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	state := alertWatchState{}
+
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+func saveAlertWatchState(path string, state alertWatchState) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// diffAlerts compares the current alerts to the previous state. Alerts are
+// matched by ID and an alert whose AlertHash differs has changed. It returns
+// the events and the new state.
+func diffAlerts(prev alertWatchState, alerts []Alert) ([]alertEvent, alertWatchState) {
+	events := []alertEvent{}
+	next := alertWatchState{}
+
+	for _, alert := range alerts {
+		next[alert.ID] = alert
+
+		old, ok := prev[alert.ID]
+		if !ok {
+			events = append(events, alertEvent{Kind: alertNew, Alert: alert})
+		} else if old.AlertHash != alert.AlertHash {
+			events = append(events, alertEvent{Kind: alertChanged, Alert: alert})
+		}
+	}
+
+	resolved := []alertEvent{}
+	for id, alert := range prev {
+		if _, ok := next[id]; !ok {
+			resolved = append(resolved, alertEvent{Kind: alertResolved, Alert: alert})
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].Alert.ID < resolved[j].Alert.ID
+	})
+
+	return append(events, resolved...), next
+}
+
+// filterAlertEvents returns the events of the alerts matching filter. A
+// resolved alert is no longer in effect, so it's matched without ActiveNow.
+func filterAlertEvents(events []alertEvent, filter alertFilter, now time.Time) []alertEvent {
+	filtered := []alertEvent{}
+	for _, e := range events {
+		f := filter
+		if e.Kind == alertResolved {
+			f.ActiveNow = false
+		}
+		if f.match(e.Alert, now) {
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered
+}
+
+func (e alertEvent) routes() string {
+	routes := []string{}
+	for _, route := range e.Alert.AffectedRoutes() {
+		routes = append(routes, route.ShortName)
+	}
+
+	return strings.Join(routes, ", ")
+}
+
+func printAlertEvent(e alertEvent) {
+	line := fmt.Sprintf("%v %-8v %-8v", time.Now().In(hsltime.Helsinki).Format("15:04 02.01"), strings.ToUpper(e.Kind), e.Alert.AlertSeverityLevel)
+	if routes := e.routes(); routes != "" {
+		line += " [" + routes + "]"
+	}
+	line += " " + e.Alert.Header(language)
+
+	switch {
+	case e.Kind == alertResolved:
+		fmt.Println(line)
+	case e.Alert.AlertSeverityLevel == "SEVERE":
+		fmt.Println(redText(line))
+	default:
+		fmt.Println(bold(line))
+	}
+}
+
+func notifyAlertEvent(n *notifier, e alertEvent) error {
+	summary := tr("HSL alert")
+	switch e.Kind {
+	case alertChanged:
+		summary = tr("HSL alert updated")
+	case alertResolved:
+		summary = tr("HSL alert resolved")
+	}
+	if routes := e.routes(); routes != "" {
+		summary += ": " + routes
+	}
+
+	urgency := urgencyNormal
+	if e.Kind == alertResolved {
+		urgency = urgencyLow
+	} else if e.Alert.AlertSeverityLevel == "SEVERE" {
+		urgency = urgencyCritical
+	}
+
+	return n.notify(summary, e.Alert.Header(language), urgency)
+}
+
+// watchAlerts polls the alerts every interval until ctx is cancelled and
+// reports the changes to the alerts matching filter since the last poll. The
+// state is saved after every poll so that a restart only reports what changed
+// while it was not running.
+// When watching all alerts for the first time, the alerts already in effect
+// are saved without reporting them, as there would be dozens.
+func watchAlerts(ctx context.Context, apikey string, filter alertFilter, interval time.Duration, statePath string, n *notifier, hooks *hookRunner) error {
+	state, err := loadAlertWatchState(statePath)
+	if err != nil {
+		return fmt.Errorf("failed to load alert state: %w", err)
+	}
+	silent := state == nil && filter.empty()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		alerts, err := getAllAlertsContext(ctx, apikey)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			fmt.Println(redText("failed to fetch alerts: " + err.Error()))
+		} else {
+			sortAlerts(alerts)
+
+			var events []alertEvent
+			events, state = diffAlerts(state, alerts)
+			events = filterAlertEvents(events, filter, time.Now())
+			if silent {
+				fmt.Printf(tr("saved the %v alerts in effect, only changes to them are reported")+"\n", len(alerts))
+				events = nil
+				silent = false
+			}
+			for _, e := range events {
+				printAlertEvent(e)
+				hooks.emit(alertHookEvent(e, time.Now()))
+
+				if n != nil {
+					if err := notifyAlertEvent(n, e); err != nil {
+						fmt.Println(redText("failed to send notification: " + err.Error()))
+					}
+				}
+			}
+
+			if err := saveAlertWatchState(statePath, state); err != nil {
+				fmt.Println(redText("failed to save alert state: " + err.Error()))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// alertsCommand implements hslterm alerts.
+func alertsCommand(args []string) {
+	fs := flag.NewFlagSet("alerts", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	watch := fs.Bool("watch", false, "Keeps polling the alerts and reports new, changed and resolved ones")
+	interval := fs.Duration("interval", time.Minute, "How often -watch polls the alerts")
+	notify := fs.Bool("notify", false, "With -watch, also sends desktop notifications over D-Bus")
+	statePath := fs.String("state", alertStateFilePath(), "File where -watch keeps the alerts it has seen")
+	tui := fs.Bool("tui", false, "Shows the alerts in a TUI view")
+	severity := fs.String("severity", "", "Only alerts with the given severities, e.g. SEVERE,WARNING")
+	effect := fs.String("effect", "", "Only alerts with the given effects, e.g. REDUCED_SERVICE")
+	cause := fs.String("cause", "", "Only alerts with the given causes, e.g. CONSTRUCTION")
+	route := fs.String("route", "", "Only alerts affecting the given routes, e.g. 550,M1 (default: favourite routes)")
+	stop := fs.String("stop", "", "Only alerts affecting the stops with the given codes (default: favourite stops)")
+	activeNow := fs.Bool("active-now", false, "Only alerts that are in effect right now")
+	fs.Parse(args)
+
+	key := resolveApikey(*apikey, *tempApikey)
+	config := mustLoadConfig()
+
+	filter := alertFilter{
+		Severities: splitFlagList(*severity),
+		Effects:    splitFlagList(*effect),
+		Causes:     splitFlagList(*cause),
+		Routes:     splitFlagList(*route),
+		Stops:      splitFlagList(*stop),
+		ActiveNow:  *activeNow,
+	}
+
+	if !*watch {
+		showAlerts(key, filter, *tui)
+		return
+	}
+
+	// Without explicit routes or stops only the favourites are watched
+	if len(filter.Routes) == 0 && len(filter.Stops) == 0 {
+		filter.Routes = config.Favourites.Routes
+		filter.Stops = config.Favourites.Stops
+	}
+
+	var n *notifier
+	if *notify {
+		var err error
+		n, err = newNotifier()
+		if err != nil {
+			fmt.Println(redText("failed to connect to D-Bus: " + err.Error()))
+			os.Exit(1)
+		}
+		defer n.Close()
+	}
+
+	hooks := newHookRunner(config, os.Stdout)
+	defer hooks.Wait()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	err := watchAlerts(ctx, key, filter, *interval, *statePath, n, hooks)
+	if err != nil {
+		fmt.Println(redText(err.Error()))
+		os.Exit(1)
+	}
+}
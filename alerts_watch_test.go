@@ -0,0 +1,84 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+func TestDiffAlerts(t *testing.T) {
+	alert := func(id string, hash int) Alert {
+		return Alert{ID: id, AlertHash: hash}
+	}
+	state := func(alerts ...Alert) alertWatchState {
+		s := alertWatchState{}
+		for _, a := range alerts {
+			s[a.ID] = a
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		prev   alertWatchState
+		alerts []Alert
+		want   []string
+	}{
+		{"first run", nil, []Alert{alert("a", 1), alert("b", 1)}, []string{"new a", "new b"}},
+		{"nothing changed", state(alert("a", 1)), []Alert{alert("a", 1)}, []string{}},
+		{"new", state(alert("a", 1)), []Alert{alert("a", 1), alert("b", 1)}, []string{"new b"}},
+		{"updated", state(alert("a", 1)), []Alert{alert("a", 2)}, []string{"changed a"}},
+		{"resolved", state(alert("a", 1), alert("c", 1), alert("b", 1)), []Alert{alert("a", 1)}, []string{"resolved b", "resolved c"}},
+		{"all at once", state(alert("a", 1), alert("b", 1)), []Alert{alert("a", 2), alert("c", 1)}, []string{"changed a", "new c", "resolved b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, next := diffAlerts(tt.prev, tt.alerts)
+
+			got := []string{}
+			for _, e := range events {
+				got = append(got, e.Kind+" "+e.Alert.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got events %v, want %v", got, tt.want)
+			}
+
+			if len(next) != len(tt.alerts) {
+				t.Errorf("got %v alerts in the state, want %v", len(next), len(tt.alerts))
+			}
+			for _, a := range tt.alerts {
+				if next[a.ID].AlertHash != a.AlertHash {
+					t.Errorf("state has %+v, want %+v", next[a.ID], a)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterAlertEvents(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, hsltime.Helsinki)
+	ended := now.Add(-time.Hour).Unix()
+
+	onRoute := func(id string, route string) Alert {
+		return Alert{ID: id, EffectiveEndDate: ended, Entities: []AlertEntity{{Typename: "Route", ShortName: route}}}
+	}
+	events := []alertEvent{
+		{Kind: alertNew, Alert: onRoute("a", "550")},
+		{Kind: alertResolved, Alert: onRoute("b", "550")},
+		{Kind: alertResolved, Alert: onRoute("c", "551")},
+	}
+
+	filtered := filterAlertEvents(events, alertFilter{Routes: []string{"550"}, ActiveNow: true}, now)
+
+	got := []string{}
+	for _, e := range filtered {
+		got = append(got, e.Kind+" "+e.Alert.ID)
+	}
+	// a has ended so it isn't active, b is resolved and ends up matching
+	if want := []string{"resolved b"}; !slices.Equal(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
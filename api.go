@@ -1,22 +1,52 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
 )
 
+// apiEndpoint is the Digitransit GraphQL endpoint all queries are sent to.
+var apiEndpoint = "https://api.digitransit.fi/routing/v1/routers/hsl/index/graphql"
+
+// accessibleOnly drops the departures that can't be boarded with a wheelchair
+// from every stop fetched.
+var accessibleOnly bool
+
+// apiObserver, if set, is told how long every API request took and whether
+// it failed.
+var apiObserver func(duration time.Duration, err error)
+
 func ApiRequest(apikey string, jsonquery string, data any) error {
+	return ApiRequestContext(context.Background(), apikey, jsonquery, data)
+}
+
+// ApiRequestContext is like ApiRequest but the request is aborted when ctx is
+// cancelled.
+func ApiRequestContext(ctx context.Context, apikey string, jsonquery string, data any) (err error) {
+	if apiObserver != nil {
+		start := time.Now()
+		defer func() {
+			apiObserver(time.Since(start), err)
+		}()
+	}
+
 	reqBody := strings.NewReader(jsonquery)
 
-	httpReq, err := http.NewRequest("POST", "https://api.digitransit.fi/routing/v1/routers/hsl/index/graphql", reqBody)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiEndpoint, reqBody)
 	if err != nil {
 		return err
 	}
 
 	httpReq.Header.Set("digitransit-subscription-key", apikey)
 	httpReq.Header.Set("Content-Type", "application/json")
+	// Headsigns, stop names and alerts come back in this language
+	httpReq.Header.Set("Accept-Language", language)
 
 	httpClient := &http.Client{}
 	httpResp, err := httpClient.Do(httpReq)
@@ -37,29 +67,135 @@ func ApiRequest(apikey string, jsonquery string, data any) error {
 	return nil
 }
 
+// alertFields are the fields requested for every alert, including the ones
+// that are part of a stop.
+const alertFields = `alertCause alertEffect alertHash alertHeaderText alertDescriptionText alertSeverityLevel alertUrl effectiveStartDate effectiveEndDate feed id ` +
+	`alertHeaderTextTranslations { text language } alertDescriptionTextTranslations { text language } ` +
+	`entities { __typename ... on Route { gtfsId shortName mode url color textColor } ... on Stop { gtfsId code name } ... on Trip { gtfsId routeShortName route { mode color textColor } } }`
+
+type TranslatedString struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+}
+
+// AlertEntity is a route, stop or trip an alert applies to. Typename tells
+// which one it is, only the fields of that type are set.
+type AlertEntity struct {
+	Typename       string `json:"__typename"`
+	GtfsID         string `json:"gtfsId"`
+	ShortName      string `json:"shortName"`
+	Mode           string `json:"mode"`
+	Url            string `json:"url"`
+	Code           string `json:"code"`
+	Name           string `json:"name"`
+	RouteShortName string `json:"routeShortName"`
+	Color          string `json:"color"`
+	TextColor      string `json:"textColor"`
+	// Route is the route of a trip entity.
+	Route *Route `json:"route"`
+}
+
 type Alert struct {
-	AlertCause         string `json:"alertCause"`
-	AlertEffect        string `json:"alertEffect"`
-	AlertHash          int    `json:"alertHash"`
-	AlertHeaderText    string `json:"alertHeaderText"`
-	AlertSeverityLevel string `json:"alertSeverityLevel"`
-	AlertUrl           string `json:"alertUrl"`
-	EffectiveStartDate int64  `json:"effectiveStartDate"`
-	EffectiveEndDate   int64  `json:"effectiveEndDate"`
-	Feed               string `json:"feed"`
-	ID                 string `json:"id"`
+	AlertCause                       string             `json:"alertCause"`
+	AlertEffect                      string             `json:"alertEffect"`
+	AlertHash                        int                `json:"alertHash"`
+	AlertHeaderText                  string             `json:"alertHeaderText"`
+	AlertHeaderTextTranslations      []TranslatedString `json:"alertHeaderTextTranslations"`
+	AlertDescriptionText             string             `json:"alertDescriptionText"`
+	AlertDescriptionTextTranslations []TranslatedString `json:"alertDescriptionTextTranslations"`
+	AlertSeverityLevel               string             `json:"alertSeverityLevel"`
+	AlertUrl                         string             `json:"alertUrl"`
+	EffectiveStartDate               int64              `json:"effectiveStartDate"`
+	EffectiveEndDate                 int64              `json:"effectiveEndDate"`
+	Entities                         []AlertEntity      `json:"entities"`
+	Feed                             string             `json:"feed"`
+	ID                               string             `json:"id"`
+}
+
+// translation returns the text in the given language, or def if there is no
+// translation for it.
+func translation(translations []TranslatedString, lang string, def string) string {
+	for _, t := range translations {
+		if t.Language == lang && t.Text != "" {
+			return t.Text
+		}
+	}
+
+	return def
+}
+
+// Header returns the alert's header text in lang, falling back to the API's
+// default language.
+func (a Alert) Header(lang string) string {
+	return translation(a.AlertHeaderTextTranslations, lang, a.AlertHeaderText)
+}
+
+// Description returns the alert's full description in lang, falling back to
+// the API's default language.
+func (a Alert) Description(lang string) string {
+	return translation(a.AlertDescriptionTextTranslations, lang, a.AlertDescriptionText)
+}
+
+// AffectedRoutes returns the route entities the alert applies to. Routes of
+// affected trips are included without a url.
+func (a Alert) AffectedRoutes() []AlertEntity {
+	seen := map[string]bool{}
+	routes := []AlertEntity{}
+	for _, entity := range a.Entities {
+		shortName := entity.ShortName
+		if entity.Typename == "Trip" {
+			shortName = entity.RouteShortName
+		} else if entity.Typename != "Route" {
+			continue
+		}
+
+		if shortName == "" || seen[shortName] {
+			continue
+		}
+		seen[shortName] = true
+
+		route := entity
+		route.ShortName = shortName
+		if entity.Route != nil {
+			route.Mode, route.Color, route.TextColor = entity.Route.Mode, entity.Route.Color, entity.Route.TextColor
+		}
+		routes = append(routes, route)
+	}
+
+	return routes
+}
+
+// route returns the route entity as a Route for colouring its name.
+func (e AlertEntity) route() Route {
+	return Route{ShortName: e.ShortName, Mode: e.Mode, Url: e.Url, Color: e.Color, TextColor: e.TextColor}
+}
+
+// AffectedStops returns the stop entities the alert applies to.
+func (a Alert) AffectedStops() []AlertEntity {
+	stops := []AlertEntity{}
+	for _, entity := range a.Entities {
+		if entity.Typename == "Stop" {
+			stops = append(stops, entity)
+		}
+	}
+
+	return stops
 }
 
 func getAllAlerts(apikey string) ([]Alert, error) {
+	return getAllAlertsContext(context.Background(), apikey)
+}
+
+func getAllAlertsContext(ctx context.Context, apikey string) ([]Alert, error) {
 	var data struct {
 		Data struct {
 			Alerts []Alert `json:"alerts"`
 		} `json:"data"`
 	}
 
-	query := `{"query": "query { alerts { alertCause alertEffect alertHeaderText alertSeverityLevel alertUrl effectiveStartDate effectiveEndDate feed id } }"}`
+	query := `{"query": "query { alerts { ` + alertFields + ` } }"}`
 
-	err := ApiRequest(apikey, query, &data)
+	err := ApiRequestContext(ctx, apikey, query, &data)
 
 	return data.Data.Alerts, err
 }
@@ -69,6 +205,31 @@ type Route struct {
 	ShortName string `json:"shortName"`
 	Mode      string `json:"mode"`
 	Url       string `json:"url"`
+	// Color and TextColor are HSL's colours for the route as hex without #,
+	// e.g. "00985F" for trams. TextColor is for text on top of Color.
+	Color     string `json:"color"`
+	TextColor string `json:"textColor"`
+}
+
+// languageArg is the GraphQL argument asking a translated field in the
+// current language, e.g. name(language: "fi"). Alerts are translated with
+// their *Translations fields instead.
+func languageArg() string {
+	return `(language: \"` + language + `\")`
+}
+
+// stopFields returns the fields requested for every stop, apart from its
+// departures.
+func stopFields() string {
+	return `alerts { ` + alertFields + ` } code desc` + languageArg() + ` direction lat lon name` + languageArg() + ` vehicleMode gtfsId zoneId wheelchairBoarding platformCode ` +
+		`routes { longName` + languageArg() + ` shortName mode url color textColor }`
+}
+
+// stopTimeFields returns the fields requested for every departure from a
+// stop.
+func stopTimeFields() string {
+	return `headsign` + languageArg() + ` realtimeState scheduledArrival realtimeArrival scheduledDeparture realtimeDeparture serviceDay stopPosition stop { platformCode } ` +
+		`trip { gtfsId routeShortName wheelchairAccessible route { mode color textColor } stoptimes { stopPosition stop { platformCode } } }`
 }
 
 type StopTimes struct {
@@ -79,8 +240,26 @@ type StopTimes struct {
 	ScheduledDeparture int64  `json:"scheduledDeparture"`
 	RealtimeDeparture  int64  `json:"realtimeDeparture"`
 	ServiceDay         int64  `json:"serviceDay"`
-	Trip               struct {
+	StopPosition       int    `json:"stopPosition"`
+	// Stop is the stop the departure actually leaves from, which can be
+	// another platform of the same station than the one that was asked for.
+	Stop struct {
+		PlatformCode string `json:"platformCode"`
+	} `json:"stop"`
+	Trip struct {
+		GtfsID         string `json:"gtfsId"`
 		RouteShortName string `json:"routeShortName"`
+		// WheelchairAccessible is POSSIBLE, NOT_POSSIBLE or NO_INFORMATION.
+		WheelchairAccessible string `json:"wheelchairAccessible"`
+		Route                Route  `json:"route"`
+		// Stoptimes are the trip's scheduled stops, which still name the
+		// original platform after a realtime platform change.
+		Stoptimes []struct {
+			StopPosition int `json:"stopPosition"`
+			Stop         struct {
+				PlatformCode string `json:"platformCode"`
+			} `json:"stop"`
+		} `json:"stoptimes"`
 	} `json:"trip"`
 }
 
@@ -96,6 +275,29 @@ type Stop struct {
 	StopTimes   []StopTimes `json:"stoptimesWithoutPatterns"`
 	VehicleMode string      `json:"vehicleMode"`
 	GtfsID      string      `json:"gtfsId"`
+	// ZoneID is the stop's fare zone, e.g. "A".
+	ZoneID string `json:"zoneId"`
+	// WheelchairBoarding is POSSIBLE, NOT_POSSIBLE or NO_INFORMATION.
+	WheelchairBoarding string `json:"wheelchairBoarding"`
+	// PlatformCode is the platform or track, e.g. "3", if the stop is one.
+	PlatformCode string `json:"platformCode"`
+}
+
+// filterAccessible removes the departures that aren't wheelchair accessible
+// if accessibleOnly is set.
+func filterAccessible(stopTimes []StopTimes) []StopTimes {
+	if !accessibleOnly {
+		return stopTimes
+	}
+
+	accessible := []StopTimes{}
+	for _, stopTime := range stopTimes {
+		if stopTime.Trip.WheelchairAccessible != "NOT_POSSIBLE" {
+			accessible = append(accessible, stopTime)
+		}
+	}
+
+	return accessible
 }
 
 func getStopData(apikey string, stopName string, stopTimesN int) ([]Stop, error) {
@@ -105,39 +307,358 @@ func getStopData(apikey string, stopName string, stopTimesN int) ([]Stop, error)
 		} `json:"data"`
 	}
 
-	query := fmt.Sprintf(`{"query": "query { stops(name: \"%v\") { alerts { alertCause alertEffect alertHeaderText alertSeverityLevel alertUrl effectiveStartDate effectiveEndDate feed id } code desc direction lat lon name vehicleMode gtfsId routes { longName shortName mode url } stoptimesWithoutPatterns (numberOfDepartures: %v) { headsign realtimeState scheduledArrival realtimeArrival scheduledDeparture realtimeDeparture serviceDay trip { routeShortName } } } }"}`, stopName, stopTimesN)
+	query := fmt.Sprintf(`{"query": "query { stops(name: \"%v\") { `+stopFields()+` stoptimesWithoutPatterns (numberOfDepartures: %v) { `+stopTimeFields()+` } } }"}`, stopName, stopTimesN)
 
 	err := ApiRequest(apikey, query, &data)
 
+	for i := range data.Data.Stops {
+		data.Data.Stops[i].StopTimes = filterAccessible(data.Data.Stops[i].StopTimes)
+	}
+
 	return data.Data.Stops, err
 }
 
-func (s *Stop) refresh(apikey string) error {
+// getStopsByCode looks up stops by their codes, e.g. H0040. Codes that don't
+// match any stop are skipped.
+func getStopsByCode(apikey string, codes []string, stopTimesN int) ([]Stop, error) {
+	stops := []Stop{}
+
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+
+		found, err := getStopData(apikey, code, stopTimesN)
+		if err != nil {
+			return stops, err
+		}
+
+		for _, stop := range found {
+			if stop.Code == code {
+				stops = append(stops, stop)
+				break
+			}
+		}
+	}
+
+	return stops, nil
+}
+
+func (s *Stop) refresh(ctx context.Context, apikey string, stopTimesN int) error {
 	var data struct {
 		Data struct {
 			Stop Stop `json:"stop"`
 		} `json:"data"`
 	}
 
-	query := fmt.Sprintf(`{"query": "query { stop(id: \"%v\") { alerts { alertCause alertEffect alertHeaderText alertSeverityLevel alertUrl effectiveStartDate effectiveEndDate feed id } code desc direction lat lon name vehicleMode gtfsId routes { longName shortName mode url } stoptimesWithoutPatterns { headsign realtimeState scheduledArrival realtimeArrival scheduledDeparture realtimeDeparture serviceDay trip { routeShortName } } } }"}`, s.GtfsID)
+	query := fmt.Sprintf(`{"query": "query { stop(id: \"%v\") { `+stopFields()+` stoptimesWithoutPatterns (numberOfDepartures: %v) { `+stopTimeFields()+` } } }"}`, s.GtfsID, stopTimesN)
 
-	err := ApiRequest(apikey, query, &data)
+	err := ApiRequestContext(ctx, apikey, query, &data)
 	if err != nil {
 		return err
 	}
 
 	*s = data.Data.Stop
+	s.StopTimes = filterAccessible(s.StopTimes)
 
 	return nil
 }
 
-func updateStopData(s []Stop, apikey string) ([]Stop, error) {
-	for i := range s {
-		err := s[i].refresh(apikey)
+// TripStopTime is a trip's arrival at and departure from one of its stops.
+type TripStopTime struct {
+	Stop struct {
+		GtfsID string `json:"gtfsId"`
+		Code   string `json:"code"`
+		Name   string `json:"name"`
+		ZoneID string `json:"zoneId"`
+	} `json:"stop"`
+	RealtimeState      string `json:"realtimeState"`
+	ScheduledArrival   int64  `json:"scheduledArrival"`
+	RealtimeArrival    int64  `json:"realtimeArrival"`
+	ScheduledDeparture int64  `json:"scheduledDeparture"`
+	RealtimeDeparture  int64  `json:"realtimeDeparture"`
+	ServiceDay         int64  `json:"serviceDay"`
+}
+
+// Trip is one run of a vehicle along a route, with all of its stops.
+type Trip struct {
+	GtfsID         string `json:"gtfsId"`
+	RouteShortName string `json:"routeShortName"`
+	TripHeadsign   string `json:"tripHeadsign"`
+	DirectionID    string `json:"directionId"`
+	Route          struct {
+		GtfsID string `json:"gtfsId"`
+		Mode   string `json:"mode"`
+	} `json:"route"`
+	StopTimes []TripStopTime `json:"stoptimesForDate"`
+}
+
+// getTrip fetches the trip with the given GTFS id as it runs on the service
+// day starting at serviceDay.
+func getTrip(ctx context.Context, apikey string, id string, serviceDay int64) (Trip, error) {
+	var data struct {
+		Data struct {
+			Trip *Trip `json:"trip"`
+		} `json:"data"`
+	}
+
+	date := hsltime.ServiceDate(serviceDay).Format("20060102")
+
+	query := fmt.Sprintf(`{"query": "query { trip(id: \"%v\") { gtfsId routeShortName tripHeadsign`+languageArg()+` directionId route { gtfsId mode } stoptimesForDate(serviceDate: \"%v\") { stop { gtfsId code name`+languageArg()+` zoneId } realtimeState scheduledArrival realtimeArrival scheduledDeparture realtimeDeparture serviceDay } } }"}`, id, date)
+
+	err := ApiRequestContext(ctx, apikey, query, &data)
+	if err != nil {
+		return Trip{}, err
+	}
+	if data.Data.Trip == nil {
+		return Trip{}, fmt.Errorf("trip %v not found", id)
+	}
+
+	return *data.Data.Trip, nil
+}
+
+// PatternStop is a stop on a route's pattern.
+type PatternStop struct {
+	GtfsID string `json:"gtfsId"`
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Desc   string `json:"desc"`
+	ZoneID string `json:"zoneId"`
+}
+
+// Pattern is one variant of a route: the sequence of stops its trips in one
+// direction stop at.
+type Pattern struct {
+	Code        string        `json:"code"`
+	Name        string        `json:"name"`
+	Headsign    string        `json:"headsign"`
+	DirectionID int           `json:"directionId"`
+	Stops       []PatternStop `json:"stops"`
+}
+
+// RouteDetails is a route with its operator and patterns.
+type RouteDetails struct {
+	GtfsID    string `json:"gtfsId"`
+	ShortName string `json:"shortName"`
+	LongName  string `json:"longName"`
+	Mode      string `json:"mode"`
+	Url       string `json:"url"`
+	Agency    struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	} `json:"agency"`
+	Patterns []Pattern `json:"patterns"`
+}
+
+// getRoutes looks up routes by name. The API also returns routes whose name
+// only starts with name, so exact matches of the short name are preferred.
+func getRoutes(apikey string, name string) ([]RouteDetails, error) {
+	var data struct {
+		Data struct {
+			Routes []RouteDetails `json:"routes"`
+		} `json:"data"`
+	}
+
+	query := fmt.Sprintf(`{"query": "query { routes(name: \"%v\") { gtfsId shortName longName`+languageArg()+` mode url agency { name url } patterns { code name headsign directionId stops { gtfsId code name`+languageArg()+` desc`+languageArg()+` zoneId } } } }"}`, name)
+
+	err := ApiRequest(apikey, query, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	exact := []RouteDetails{}
+	for _, route := range data.Data.Routes {
+		if strings.EqualFold(route.ShortName, name) {
+			exact = append(exact, route)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, nil
+	}
+
+	return data.Data.Routes, nil
+}
+
+// getPatternTimetable fetches the scheduled departures of pattern from the
+// stop with the given GTFS id on date, e.g. "20250131".
+func getPatternTimetable(apikey string, stopID string, pattern string, date string) ([]StopTimes, error) {
+	var data struct {
+		Data struct {
+			Stop struct {
+				StoptimesForServiceDate []struct {
+					Pattern struct {
+						Code string `json:"code"`
+					} `json:"pattern"`
+					Stoptimes []StopTimes `json:"stoptimes"`
+				} `json:"stoptimesForServiceDate"`
+			} `json:"stop"`
+		} `json:"data"`
+	}
+
+	query := fmt.Sprintf(`{"query": "query { stop(id: \"%v\") { stoptimesForServiceDate(date: \"%v\") { pattern { code } stoptimes { `+stopTimeFields()+` } } } }"}`, stopID, date)
+
+	err := ApiRequest(apikey, query, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range data.Data.Stop.StoptimesForServiceDate {
+		if p.Pattern.Code == pattern {
+			return p.Stoptimes, nil
+		}
+	}
+
+	return []StopTimes{}, nil
+}
+
+// BikeStation is a city bike station.
+type BikeStation struct {
+	StationID         string  `json:"stationId"`
+	Name              string  `json:"name"`
+	Lat               float64 `json:"lat"`
+	Lon               float64 `json:"lon"`
+	Operative         bool    `json:"operative"`
+	AllowPickup       bool    `json:"allowPickup"`
+	AllowDropoff      bool    `json:"allowDropoff"`
+	Realtime          bool    `json:"realtime"`
+	Capacity          int     `json:"capacity"`
+	AvailableVehicles struct {
+		Total int `json:"total"`
+	} `json:"availableVehicles"`
+	AvailableSpaces struct {
+		Total int `json:"total"`
+	} `json:"availableSpaces"`
+}
+
+// getBikeStations fetches every city bike station.
+func getBikeStations(ctx context.Context, apikey string) ([]BikeStation, error) {
+	var data struct {
+		Data struct {
+			Stations []BikeStation `json:"vehicleRentalStations"`
+		} `json:"data"`
+	}
+
+	query := `{"query": "query { vehicleRentalStations { stationId name lat lon operative allowPickup allowDropoff realtime capacity availableVehicles { total } availableSpaces { total } } }"}`
+
+	err := ApiRequestContext(ctx, apikey, query, &data)
+
+	return data.Data.Stations, err
+}
+
+// ParkingSpaces are the parking spaces of a facility by vehicle type.
+type ParkingSpaces struct {
+	CarSpaces     int `json:"carSpaces"`
+	BicycleSpaces int `json:"bicycleSpaces"`
+}
+
+// VehicleParking is a park and ride facility. Availability is nil if the
+// facility doesn't report its free spaces in realtime.
+type VehicleParking struct {
+	VehicleParkingID string         `json:"vehicleParkingId"`
+	Name             string         `json:"name"`
+	Lat              float64        `json:"lat"`
+	Lon              float64        `json:"lon"`
+	Realtime         bool           `json:"realtime"`
+	State            string         `json:"state"`
+	CarPlaces        bool           `json:"carPlaces"`
+	BicyclePlaces    bool           `json:"bicyclePlaces"`
+	Capacity         ParkingSpaces  `json:"capacity"`
+	Availability     *ParkingSpaces `json:"availability"`
+}
+
+// getVehicleParkings fetches every park and ride facility.
+func getVehicleParkings(apikey string) ([]VehicleParking, error) {
+	var data struct {
+		Data struct {
+			VehicleParkings []VehicleParking `json:"vehicleParkings"`
+		} `json:"data"`
+	}
+
+	query := `{"query": "query { vehicleParkings { vehicleParkingId name lat lon realtime state carPlaces bicyclePlaces capacity { carSpaces bicycleSpaces } availability { carSpaces bicycleSpaces } } }"}`
+
+	err := ApiRequest(apikey, query, &data)
+
+	return data.Data.VehicleParkings, err
+}
+
+// Place is where a leg of an itinerary starts or ends. Stop is nil if it isn't
+// at a stop, e.g. at the start of a walk.
+type Place struct {
+	Name string `json:"name"`
+	Stop *struct {
+		Code   string `json:"code"`
+		Name   string `json:"name"`
+		ZoneID string `json:"zoneId"`
+	} `json:"stop"`
+}
+
+// FareProduct is a ticket the routing API says is valid for a leg.
+type FareProduct struct {
+	ID      string `json:"id"`
+	Product struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Price *struct {
+			Amount   float64 `json:"amount"`
+			Currency struct {
+				Code string `json:"code"`
+			} `json:"currency"`
+		} `json:"price"`
+	} `json:"product"`
+}
+
+// Leg is one part of an itinerary, a walk or a ride on one vehicle.
+type Leg struct {
+	Mode       string `json:"mode"`
+	TransitLeg bool   `json:"transitLeg"`
+	// StartTime and EndTime are in milliseconds since the epoch.
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime"`
+	Route     *struct {
+		ShortName string `json:"shortName"`
+	} `json:"route"`
+	From               Place         `json:"from"`
+	To                 Place         `json:"to"`
+	IntermediatePlaces []Place       `json:"intermediatePlaces"`
+	FareProducts       []FareProduct `json:"fareProducts"`
+}
+
+// Itinerary is one way of getting from one place to another.
+type Itinerary struct {
+	// StartTime and EndTime are in milliseconds since the epoch.
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime"`
+	Legs      []Leg `json:"legs"`
+}
+
+// getItineraries plans journeys between two coordinates, leaving now.
+func getItineraries(apikey string, fromLat, fromLon, toLat, toLon float64, n int) ([]Itinerary, error) {
+	var data struct {
+		Data struct {
+			Plan struct {
+				Itineraries []Itinerary `json:"itineraries"`
+			} `json:"plan"`
+		} `json:"data"`
+	}
+
+	placeFields := `name stop { code name` + languageArg() + ` zoneId }`
+	query := fmt.Sprintf(`{"query": "query { plan(from: {lat: %v, lon: %v}, to: {lat: %v, lon: %v}, numItineraries: %v) { itineraries { startTime endTime legs { mode transitLeg startTime endTime route { shortName } from { `+placeFields+` } to { `+placeFields+` } intermediatePlaces { `+placeFields+` } fareProducts { id product { id name ... on DefaultFareProduct { price { amount currency { code } } } } } } } } }"}`, fromLat, fromLon, toLat, toLon, n)
+
+	err := ApiRequest(apikey, query, &data)
+
+	return data.Data.Plan.Itineraries, err
+}
+
+// updateStopData fetches fresh data for every stop in s. The result is a new
+// slice, s itself is left untouched.
+func updateStopData(ctx context.Context, s []Stop, apikey string) ([]Stop, error) {
+	updated := make([]Stop, len(s))
+	copy(updated, s)
+
+	for i := range updated {
+		err := updated[i].refresh(ctx, apikey, 5)
 		if err != nil {
 			return s, err
 		}
 	}
 
-	return s, nil
+	return updated, nil
 }
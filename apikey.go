@@ -2,10 +2,8 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 )
 
 var (
@@ -13,21 +11,7 @@ var (
 )
 
 func apikeyFilePath() string {
-	var path string
-	if runtime.GOOS == "windows" {
-		// On Windows, use %APPDATA%\hslterm
-		roaming := os.Getenv("APPDATA")
-		path = filepath.Join(roaming, appName, "apikey.txt")
-	} else {
-		// On Linux/macOS, use ~/.config/hslterm
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Println("Error finding home directory:", err)
-			return ""
-		}
-		path = filepath.Join(home, ".config", appName, "apikey.txt")
-	}
-	return path
+	return configFilePath("apikey.txt")
 }
 
 func saveApiKey(key string) error {
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// nearbyStation is a bike station with its distance in metres from where the
+// user searched, or -1 if they searched by name.
+type nearbyStation struct {
+	BikeStation
+	Distance float64
+}
+
+// bikeStationState describes whether bikes can be taken from and returned to
+// the station, in English. Show it with tr.
+func bikeStationState(s BikeStation) string {
+	switch {
+	case !s.Operative:
+		return "Closed"
+	case !s.AllowPickup && !s.AllowDropoff:
+		return "No pickups or returns"
+	case !s.AllowPickup:
+		return "No pickups"
+	case !s.AllowDropoff:
+		return "No returns"
+	case !s.Realtime:
+		return "No realtime data"
+	}
+
+	return "Open"
+}
+
+// distanceMetres is the great-circle distance between two coordinates.
+func distanceMetres(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// placeSearch selects places, such as bike stations, by name or by distance
+// from a point.
+type placeSearch struct {
+	Name   string
+	Near   bool
+	Lat    float64
+	Lon    float64
+	Radius float64
+}
+
+// match reports whether the place with the given name and coordinates
+// matches and how far it is, -1 when not searching by location.
+func (p placeSearch) match(name string, lat float64, lon float64) (float64, bool) {
+	if p.Name != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(p.Name)) {
+		return 0, false
+	}
+
+	if !p.Near {
+		return -1, true
+	}
+
+	distance := distanceMetres(p.Lat, p.Lon, lat, lon)
+	return distance, distance <= p.Radius
+}
+
+// findBikeStations returns the stations that match search, the nearest first
+// when searching by location and alphabetically otherwise.
+func findBikeStations(stations []BikeStation, search placeSearch) []nearbyStation {
+	found := []nearbyStation{}
+	for _, station := range stations {
+		if distance, ok := search.match(station.Name, station.Lat, station.Lon); ok {
+			found = append(found, nearbyStation{BikeStation: station, Distance: distance})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if search.Near {
+			return found[i].Distance < found[j].Distance
+		}
+		return found[i].Name < found[j].Name
+	})
+
+	return found
+}
+
+// parseNear parses -near, either "LAT,LON" or the code of a stop.
+func parseNear(apikey string, near string) (float64, float64, error) {
+	if lat, lon, ok := strings.Cut(near, ","); ok {
+		latF, err1 := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		lonF, err2 := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if err1 == nil && err2 == nil {
+			return latF, lonF, nil
+		}
+	}
+
+	stops, err := getStopsByCode(apikey, []string{near}, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(stops) == 0 {
+		return 0, 0, fmt.Errorf("%v is neither coordinates nor a stop code", near)
+	}
+
+	return stops[0].Lat, stops[0].Lon, nil
+}
+
+func formatDistance(metres float64) string {
+	if metres < 0 {
+		return ""
+	}
+	if metres >= 1000 {
+		return fmt.Sprintf("%.1fkm", metres/1000)
+	}
+
+	return fmt.Sprintf("%.0fm", metres)
+}
+
+func printBikeStations(stations []nearbyStation, near bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{tr("Station"), tr("Bikes"), tr("Free docks"), tr("State")}
+	if near {
+		header = append(header, tr("Distance"))
+	}
+	t.AppendHeader(header)
+
+	t.SetStyle(table.StyleRounded)
+
+	for _, s := range stations {
+		bikes := fmt.Sprint(s.AvailableVehicles.Total)
+		if s.AvailableVehicles.Total == 0 {
+			bikes = redText(bikes)
+		}
+		docks := fmt.Sprint(s.AvailableSpaces.Total)
+		if s.AvailableSpaces.Total == 0 {
+			docks = redText(docks)
+		}
+		state := tr(bikeStationState(s.BikeStation))
+		if bikeStationState(s.BikeStation) != "Open" {
+			state = redText(state)
+		}
+
+		row := table.Row{fmt.Sprintf("%v (%v)", bold(s.Name), s.StationID), bikes, docks, state}
+		if near {
+			row = append(row, formatDistance(s.Distance))
+		}
+		t.AppendRow(row)
+	}
+
+	t.Render()
+}
+
+// bikesCommand implements hslterm bikes.
+func bikesCommand(args []string) {
+	fs := flag.NewFlagSet("bikes", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	near := fs.String("near", "", "Find stations near a place, LAT,LON or a stop code, e.g. H0040")
+	radius := fs.Float64("radius", 500, "With -near, how far to look in metres")
+	n := fs.Int("n", 10, "Maximum number of stations to show")
+	tui := fs.Bool("tui", false, "Show the stations in a realtime updating tui view")
+
+	// Allow the name before the flags, e.g. hslterm bikes kamppi -tui
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" {
+		name = strings.Join(fs.Args(), " ")
+	}
+
+	if name == "" && *near == "" {
+		fmt.Println(redText(tr("give a station name or a place with -near, e.g. hslterm bikes kamppi")))
+		os.Exit(1)
+	}
+
+	key := resolveApikey(*apikey, *tempApikey)
+
+	search := placeSearch{Name: name, Radius: *radius}
+	if *near != "" {
+		var err error
+		search.Lat, search.Lon, err = parseNear(key, *near)
+		if err != nil {
+			fmt.Println(redText("got err " + err.Error()))
+			os.Exit(1)
+		}
+		search.Near = true
+	}
+
+	all, err := getBikeStations(context.Background(), key)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	stations := findBikeStations(all, search)
+	if len(stations) > *n {
+		stations = stations[:*n]
+	}
+	if len(stations) == 0 {
+		fmt.Println(tr("no bike stations found"))
+		os.Exit(0)
+	}
+
+	if *tui {
+		tuiDisplayBikes(stations, search, key)
+		return
+	}
+
+	printBikeStations(stations, search.Near)
+}
+
+// bikeBoard is the tui board of bike stations.
+type bikeBoard struct {
+	*tview.Frame
+
+	table    *tview.Table
+	stations []nearbyStation
+	near     bool
+	status   string
+}
+
+func newBikeBoard(stations []nearbyStation, near bool) *bikeBoard {
+	table := tview.NewTable().
+		SetBorders(true).
+		SetFixed(1, 0).
+		SetSelectable(true, false)
+
+	board := &bikeBoard{
+		Frame: tview.NewFrame(table).SetBorders(1, 1, 2, 2, 4, 4),
+		table: table,
+		near:  near,
+	}
+	board.update(stations)
+
+	return board
+}
+
+// update shows fresh counts for the board's stations.
+func (b *bikeBoard) update(stations []nearbyStation) {
+	b.stations = stations
+
+	header := []string{tr("Station"), tr("Bikes"), tr("Free docks"), tr("State")}
+	if b.near {
+		header = append(header, tr("Distance"))
+	}
+	for col, title := range header {
+		b.table.SetCell(0, col, tview.NewTableCell(title).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false))
+	}
+
+	for i, s := range stations {
+		color := func(n int) string {
+			if n == 0 {
+				return colorTag(theme.Warning, "")
+			}
+			return colorTag(theme.Text, "")
+		}
+
+		state := bikeStationState(s.BikeStation)
+		stateColor := colorTag(theme.Text, "")
+		if state != "Open" {
+			stateColor = colorTag(theme.Warning, "")
+		}
+
+		b.table.SetCellSimple(i+1, 0, fmt.Sprintf("%v%v (%v)", colorTag(theme.Text, ""), tview.Escape(s.Name), s.StationID))
+		b.table.SetCellSimple(i+1, 1, color(s.AvailableVehicles.Total)+fmt.Sprint(s.AvailableVehicles.Total))
+		b.table.SetCellSimple(i+1, 2, color(s.AvailableSpaces.Total)+fmt.Sprint(s.AvailableSpaces.Total))
+		b.table.SetCellSimple(i+1, 3, stateColor+tr(state))
+		if b.near {
+			b.table.SetCellSimple(i+1, 4, formatDistance(s.Distance))
+		}
+	}
+
+	b.drawHeader()
+}
+
+func (b *bikeBoard) setStatus(status string) {
+	b.status = status
+	b.drawHeader()
+}
+
+func (b *bikeBoard) drawHeader() {
+	b.Clear().
+		AddText("hslterm", true, tview.AlignLeft, themeColor(theme.Text)).
+		AddText(time.Now().In(hsltime.Helsinki).Format("15:04:05 02.01.2006"), true, tview.AlignRight, themeColor(theme.Text)).
+		AddText("🚲 "+tr("City bikes")+" 🚲", true, tview.AlignCenter, themeColor(theme.Title))
+
+	if b.status != "" {
+		b.AddText(b.status, false, tview.AlignCenter, themeColor(theme.Warning))
+	}
+}
+
+// refreshBikeStations returns fresh data for stations from all.
+func refreshBikeStations(stations []nearbyStation, all []BikeStation) []nearbyStation {
+	byID := map[string]BikeStation{}
+	for _, station := range all {
+		byID[station.StationID] = station
+	}
+
+	updated := make([]nearbyStation, len(stations))
+	for i, station := range stations {
+		updated[i] = station
+		if fresh, ok := byID[station.StationID]; ok {
+			updated[i].BikeStation = fresh
+		}
+	}
+
+	return updated
+}
+
+// tuiDisplayBikes shows the stations on a board that is refreshed as often as
+// the departure boards.
+func tuiDisplayBikes(stations []nearbyStation, search placeSearch, apikey string) {
+	app := tview.NewApplication()
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRune:
+			if event.Rune() == 'q' {
+				app.Stop()
+			}
+		case tcell.KeyCtrlC, tcell.KeyEsc:
+			app.Stop()
+		}
+		return event
+	})
+
+	board := newBikeBoard(stations, search.Near)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		clock := time.NewTicker(time.Second)
+		defer clock.Stop()
+		refresh := time.NewTicker(departuresRefreshInterval)
+		defer refresh.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.C:
+				app.QueueUpdateDraw(board.drawHeader)
+			case <-refresh.C:
+				all, err := getBikeStations(ctx, apikey)
+				if ctx.Err() != nil {
+					return
+				}
+
+				app.QueueUpdateDraw(func() {
+					if err != nil {
+						board.setStatus(tr("refresh failed: ") + err.Error())
+						return
+					}
+
+					board.status = ""
+					board.update(refreshBikeStations(board.stations, all))
+				})
+			}
+		}
+	}()
+
+	err := app.SetRoot(board, true).Run()
+	cancel()
+	if err != nil {
+		panic(err)
+	}
+}
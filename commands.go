@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// subcommands are run as hslterm COMMAND [OPTIONS]. Each command parses its
+// own flags from args.
+var subcommands = map[string]func(args []string){
+	"alerts":   alertsCommand,
+	"remind":   remindCommand,
+	"serve":    serveCommand,
+	"exporter": exporterCommand,
+	"record":   recordCommand,
+	"stats":    statsCommand,
+	"vehicles": vehiclesCommand,
+	"line":     lineCommand,
+	"bikes":    bikesCommand,
+	"parking":  parkingCommand,
+	"journey":  journeyCommand,
+}
+
+// addApikeyFlags registers the flags every command using the API accepts:
+// the api key and the display flags.
+func addApikeyFlags(fs *flag.FlagSet) (apikey *string, tempApikey *string) {
+	apikey = fs.String("apikey", "", "Sets the API key. Stores it in ~/.config/hslterm/apikey.txt")
+	tempApikey = fs.String("temp-apikey", "", "Sets a temporary API key for the duration of one command")
+	addDisplayFlags(fs)
+
+	return
+}
+
+// addDisplayFlags registers the flags of every command printing something:
+// the language and the theme.
+func addDisplayFlags(fs *flag.FlagSet) {
+	fs.Func("lang", "Language of the texts, en, fi or sv (default from $LANG)", setLanguage)
+	fs.Func("theme", "Colour theme, default, high-contrast, monochrome, hsl or one from the config file", setTheme)
+}
+
+// resolveApikey returns the api key to use. A temporary key wins, a given key
+// is saved for later runs and without either the saved key is loaded.
+func resolveApikey(apikey string, tempApikey string) string {
+	if tempApikey != "" {
+		return tempApikey
+	}
+
+	if apikey == "" {
+		apikey, err := loadApikey()
+		if err != nil {
+			fmt.Println(redText("error when loading apikey: " + err.Error()))
+			os.Exit(1)
+		}
+
+		return apikey
+	}
+
+	err := saveApiKey(apikey)
+	if err != nil {
+		fmt.Println(redText("failed to save api key: " + err.Error()))
+		os.Exit(1)
+	}
+
+	return apikey
+}
+
+func mustLoadConfig() Config {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Println(redText("error when loading config: " + err.Error()))
+		os.Exit(1)
+	}
+
+	return config
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Config is the user's configuration. It is stored as JSON in
+// ~/.config/hslterm/config.json, command line flags take precedence over it.
+type Config struct {
+	Dashboard  DashboardConfig  `json:"dashboard"`
+	Kiosk      KioskConfig      `json:"kiosk"`
+	Favourites FavouritesConfig `json:"favourites"`
+	Vehicles   VehiclesConfig   `json:"vehicles"`
+	Hooks      []HookConfig     `json:"hooks"`
+	// HookConcurrency is how many hooks may run at the same time.
+	HookConcurrency int `json:"hookConcurrency"`
+	// Theme is the name of the colour theme, built in or from Themes.
+	Theme string `json:"theme"`
+	// Themes are the user's own colour themes by name.
+	Themes map[string]Theme `json:"themes"`
+}
+
+// HookConfig is a command that is run when an event happens. The event is
+// given to the command as HSLTERM_* environment variables and as JSON on
+// stdin.
+type HookConfig struct {
+	// Event is one of "departure", "canceled", "alert" or "leave".
+	Event string `json:"event"`
+	// Command is run with sh -c.
+	Command string `json:"command"`
+	// Within is, for departure events, how many minutes before the departure
+	// the hook runs.
+	Within int `json:"within"`
+	// Routes and Stops limit the hook to events on these routes and stops.
+	Routes []string `json:"routes"`
+	Stops  []string `json:"stops"`
+	// Timeout is how long the command may run, e.g. "10s". Default 30s.
+	Timeout string `json:"timeout"`
+}
+
+// VehiclesConfig holds the settings for realtime vehicle positions.
+type VehiclesConfig struct {
+	// Broker is the URL of the MQTT broker publishing HSL's high-frequency
+	// positioning feed, e.g. "tcp://localhost:1883". Default is HSL's own.
+	Broker string `json:"broker"`
+}
+
+// FavouritesConfig lists the routes and stops the user cares about. Watch
+// modes only report on these unless told otherwise.
+type FavouritesConfig struct {
+	// Routes are route short names, e.g. "550" or "M1".
+	Routes []string `json:"routes"`
+	// Stops are stop codes, e.g. "H0040".
+	Stops []string `json:"stops"`
+}
+
+// DashboardConfig holds the defaults for -dashboard.
+type DashboardConfig struct {
+	// Stops are the codes of the stops to show, e.g. "H0040".
+	Stops []string `json:"stops"`
+	// Merged shows all departures on one board instead of one board per stop.
+	Merged bool `json:"merged"`
+	// Columns is the number of boards side by side, 0 fits them to the terminal.
+	Columns int `json:"columns"`
+}
+
+// KioskConfig holds the settings for -kiosk.
+type KioskConfig struct {
+	// Stops are the codes of the stops to rotate between.
+	Stops []string `json:"stops"`
+	// Rotate is how long each stop is shown, e.g. "15s".
+	Rotate string `json:"rotate"`
+	// ExitKey is the only key chord that is not ignored, e.g. "Ctrl+Q".
+	ExitKey string `json:"exitKey"`
+	// Departures is the number of departures shown per stop.
+	Departures int `json:"departures"`
+}
+
+// configFilePath returns the path of the named file in hslterm's config
+// directory.
+func configFilePath(name string) string {
+	var path string
+	if runtime.GOOS == "windows" {
+		// On Windows, use %APPDATA%\hslterm
+		roaming := os.Getenv("APPDATA")
+		path = filepath.Join(roaming, appName, name)
+	} else {
+		// On Linux/macOS, use ~/.config/hslterm
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Println("Error finding home directory:", err)
+			return ""
+		}
+		path = filepath.Join(home, ".config", appName, name)
+	}
+	return path
+}
+
+// loadConfig reads the config file. A missing file is not an error, an empty
+// config is returned instead.
+func loadConfig() (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(configFilePath("config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// dashboardBoardWidth is the narrowest a stop board can get before the
+// dashboard moves it to another row.
+const dashboardBoardWidth = 60
+
+// departure is a single departure together with the stop it leaves from.
+type departure struct {
+	Stop     Stop
+	StopTime StopTimes
+}
+
+// mergeDepartures returns the departures of all stops in chronological order.
+func mergeDepartures(stops []Stop) []departure {
+	departures := []departure{}
+	for _, stop := range stops {
+		for _, stopTime := range stop.StopTimes {
+			departures = append(departures, departure{Stop: stop, StopTime: stopTime})
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		a, b := departures[i].StopTime, departures[j].StopTime
+		return a.ServiceDay+a.RealtimeDeparture < b.ServiceDay+b.RealtimeDeparture
+	})
+
+	return departures
+}
+
+// mergedBoard shows the departures of several stops on a single board.
+type mergedBoard struct {
+	*tview.Frame
+
+	table      *tview.Table
+	stops      []Stop
+	departures []departure
+	status     string
+}
+
+func newTuiMergedFrame(stops []Stop) *mergedBoard {
+	table := tview.NewTable().
+		SetBorders(true).
+		SetFixed(1, 4).
+		SetSelectable(true, false).
+		SetCell(0, 0, tview.NewTableCell(tr("Stop")).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false)).
+		SetCell(0, 1, tview.NewTableCell(tr("Route")).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false)).
+		SetCell(0, 2, tview.NewTableCell(tr("Departing")).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false)).
+		SetCell(0, 3, tview.NewTableCell(tr("Time left")).SetExpansion(1).SetSelectable(false))
+
+	board := &mergedBoard{
+		Frame: tview.NewFrame(table).SetBorders(1, 1, 2, 2, 4, 4),
+		table: table,
+	}
+	board.update(stops)
+
+	return board
+}
+
+func (b *mergedBoard) update(stops []Stop) {
+	row, _ := b.table.GetSelection()
+
+	b.stops = stops
+	b.departures = mergeDepartures(stops)
+
+	for b.table.GetRowCount() > len(b.departures)+1 {
+		b.table.RemoveRow(b.table.GetRowCount() - 1)
+	}
+
+	for i, d := range b.departures {
+		departs := departureTime(d.StopTime)
+
+		stop := fmt.Sprintf("%v %v (%v)", transportModeEmoji(d.Stop.VehicleMode), d.Stop.Name, d.Stop.Code)
+		if platform, scheduled, changed := departurePlatform(d.Stop, d.StopTime); changed {
+			stop += " " + colorTag(theme.Warning, "b") + fmt.Sprintf(tr("platform %v"), fmt.Sprintf(tr("%v (was %v)"), platform, scheduled)) + "[-::-]"
+		} else if platform != "" {
+			stop += " " + fmt.Sprintf(tr("platform %v"), platform)
+		}
+		b.table.SetCellSimple(i+1, 0, stop)
+		b.table.SetCellSimple(i+1, 1, tuiRouteName(d.StopTime))
+		b.table.SetCellSimple(i+1, 2, hsltime.FormatClock(departs))
+		b.table.SetCellSimple(i+1, 3, hsltime.FormatRelative(departs, time.Now(), tr))
+	}
+
+	if row >= b.table.GetRowCount() {
+		row = b.table.GetRowCount() - 1
+	}
+	if row < 1 {
+		row = 1
+	}
+	b.table.Select(row, 0)
+
+	b.drawHeader()
+}
+
+func (b *mergedBoard) tick() {
+	for i, d := range b.departures {
+		b.table.GetCell(i+1, 3).SetText(hsltime.FormatRelative(departureTime(d.StopTime), time.Now(), tr))
+	}
+
+	b.drawHeader()
+}
+
+func (b *mergedBoard) drawHeader() {
+	names := []string{}
+	for _, stop := range b.stops {
+		names = append(names, withZone(stop.Name, stop.Code, stop.ZoneID))
+	}
+
+	b.Clear().
+		AddText("hslterm", true, tview.AlignLeft, themeColor(theme.Text)).
+		AddText(time.Now().In(hsltime.Helsinki).Format("15:04:05 02.01.2006"), true, tview.AlignRight, themeColor(theme.Text)).
+		AddText(tr("All departures"), true, tview.AlignCenter, themeColor(theme.Text)).
+		AddText(strings.Join(names, ", "), false, tview.AlignCenter, themeColor(theme.Info))
+
+	if b.status != "" {
+		b.AddText(b.status, false, tview.AlignCenter, themeColor(theme.Warning))
+	}
+}
+
+// dashboardColumns returns how many boards fit side by side on a terminal of
+// the given width. A positive columns overrides the automatic sizing.
+func dashboardColumns(boards, width, columns int) int {
+	if columns <= 0 {
+		columns = width / dashboardBoardWidth
+	}
+
+	if columns > boards {
+		columns = boards
+	}
+	if columns < 1 {
+		columns = 1
+	}
+
+	return columns
+}
+
+func layoutDashboard(grid *tview.Grid, boards []*stopBoard, columns int, focused int) {
+	rows := (len(boards) + columns - 1) / columns
+
+	grid.Clear().
+		SetRows(make([]int, rows)...).
+		SetColumns(make([]int, columns)...)
+
+	for i, board := range boards {
+		grid.AddItem(board, i/columns, i%columns, 1, 1, 0, 0, i == focused)
+	}
+}
+
+// tuiDisplayDashboard shows several stops at once, either as a grid of stop
+// boards or merged into one board.
+func tuiDisplayDashboard(stops []Stop, apikey string, config DashboardConfig, hooks *hookRunner) {
+	if len(stops) == 0 {
+		fmt.Println(tr("no stops found"))
+		os.Exit(0)
+	}
+
+	app := tview.NewApplication()
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRune:
+			if event.Rune() == 'q' {
+				app.Stop()
+			}
+		case tcell.KeyCtrlC, tcell.KeyEsc:
+			app.Stop()
+		}
+		return event
+	})
+
+	var root tview.Primitive
+	var merged *mergedBoard
+	boards := []*stopBoard{}
+
+	if config.Merged {
+		merged = newTuiMergedFrame(stops)
+		root = merged
+	} else {
+		for _, stop := range stops {
+			board, err := newTuiStopFrame(stop, "", "")
+			if err != nil {
+				os.Exit(1)
+			}
+			boards = append(boards, board)
+		}
+
+		width, err := getTerminalWidth()
+		if err != nil {
+			width = 80
+		}
+
+		focused := 0
+		columns := dashboardColumns(len(boards), width, config.Columns)
+		grid := tview.NewGrid()
+		layoutDashboard(grid, boards, columns, focused)
+
+		// Re-tile the boards when the terminal is resized
+		app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+			width, _ := screen.Size()
+			if c := dashboardColumns(len(boards), width, config.Columns); c != columns {
+				columns = c
+				layoutDashboard(grid, boards, columns, focused)
+			}
+			return false
+		})
+
+		// Tab moves the selection to the next board
+		grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyTab {
+				focused = (focused + 1) % len(boards)
+				app.SetFocus(boards[focused])
+				return nil
+			}
+			return event
+		})
+
+		root = grid
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		clock := time.NewTicker(time.Second)
+		defer clock.Stop()
+
+		snapshots := watchStops(ctx, apikey, stops, departuresRefreshInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.C:
+				app.QueueUpdateDraw(func() {
+					if merged != nil {
+						merged.tick()
+					}
+					for _, board := range boards {
+						board.tick()
+					}
+				})
+			case snapshot, ok := <-snapshots:
+				if !ok {
+					return
+				}
+
+				if snapshot.Err == nil {
+					hooks.emit(stopEvents(snapshot.Stops, snapshot.Fetched)...)
+				}
+
+				app.QueueUpdateDraw(func() {
+					status := ""
+					if snapshot.Err != nil {
+						status = tr("refresh failed: ") + snapshot.Err.Error()
+					}
+
+					if merged != nil {
+						merged.status = status
+						merged.update(snapshot.Stops)
+					}
+					for i, board := range boards {
+						board.status = status
+						board.update(snapshot.Stops[i], "", "")
+					}
+				})
+			}
+		}
+	}()
+
+	err := app.SetRoot(root, true).Run()
+	cancel()
+	if err != nil {
+		panic(err)
+	}
+}
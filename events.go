@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of Event
+const (
+	// eventDeparture is a departure that leaves within a hook's Within minutes
+	eventDeparture = "departure"
+	// eventCanceled is a canceled departure
+	eventCanceled = "canceled"
+	// eventAlert is a new, changed or resolved alert
+	eventAlert = "alert"
+	// eventLeave is a reminder that it's time to leave for a departure
+	eventLeave = "leave"
+)
+
+const (
+	defaultHookTimeout     = 30 * time.Second
+	defaultHookConcurrency = 4
+	// hookSeenGrace is how long after its departure an event is remembered,
+	// in case the departure is still on the board for a while
+	hookSeenGrace = 10 * time.Minute
+)
+
+// Event is something that happened that hooks can act on. It is passed to
+// the hook's command as JSON on stdin.
+type Event struct {
+	Type        string     `json:"type"`
+	Time        time.Time  `json:"time"`
+	StopCode    string     `json:"stopCode,omitempty"`
+	StopName    string     `json:"stopName,omitempty"`
+	Route       string     `json:"route,omitempty"`
+	Headsign    string     `json:"headsign,omitempty"`
+	Departure   *time.Time `json:"departure,omitempty"`
+	MinutesLeft *int       `json:"minutesLeft,omitempty"`
+	// LeaveAt is, for leave events, when to leave for the departure.
+	LeaveAt   *time.Time `json:"leaveAt,omitempty"`
+	AlertKind string     `json:"alertKind,omitempty"`
+	Alert     *Alert     `json:"alert,omitempty"`
+
+	// key identifies the event so a hook runs only once for it even if it is
+	// seen on many refreshes.
+	key string
+}
+
+// env returns the event as HSLTERM_* environment variables.
+func (e Event) env() []string {
+	env := []string{
+		"HSLTERM_EVENT=" + e.Type,
+		"HSLTERM_TIME=" + e.Time.Format(time.RFC3339),
+	}
+
+	add := func(name string, value string) {
+		if value != "" {
+			env = append(env, "HSLTERM_"+name+"="+value)
+		}
+	}
+	add("STOP_CODE", e.StopCode)
+	add("STOP_NAME", e.StopName)
+	add("ROUTE", e.Route)
+	add("HEADSIGN", e.Headsign)
+	if e.Departure != nil {
+		add("DEPARTURE", e.Departure.Format(time.RFC3339))
+		add("MINUTES_LEFT", strconv.Itoa(*e.MinutesLeft))
+	}
+	if e.LeaveAt != nil {
+		add("LEAVE_AT", e.LeaveAt.Format(time.RFC3339))
+	}
+	if e.Alert != nil {
+		add("ALERT_KIND", e.AlertKind)
+		add("ALERT_ID", e.Alert.ID)
+		add("ALERT_SEVERITY", e.Alert.AlertSeverityLevel)
+		add("ALERT_HEADER", e.Alert.Header(language))
+	}
+
+	return env
+}
+
+// stopEvents returns a departure event for every departure of the stops and
+// a canceled event for every canceled one.
+func stopEvents(stops []Stop, now time.Time) []Event {
+	events := []Event{}
+	for _, stop := range stops {
+		for _, stopTime := range stop.StopTimes {
+			departs := departureTime(stopTime)
+			minutesLeft := int(departs.Sub(now).Minutes())
+			e := Event{
+				Type:        eventDeparture,
+				Time:        now,
+				StopCode:    stop.Code,
+				StopName:    stop.Name,
+				Route:       stopTime.Trip.RouteShortName,
+				Headsign:    stopTime.Headsign,
+				Departure:   &departs,
+				MinutesLeft: &minutesLeft,
+				key:         stop.Code + "/" + departureKey(stopTime),
+			}
+
+			if stopTime.RealtimeState == "CANCELED" {
+				e.Type = eventCanceled
+			}
+
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// alertHookEvent turns a change seen by the alert watcher into an Event.
+func alertHookEvent(e alertEvent, now time.Time) Event {
+	alert := e.Alert
+	routes := []string{}
+	for _, route := range alert.AffectedRoutes() {
+		routes = append(routes, route.ShortName)
+	}
+
+	return Event{
+		Type:      eventAlert,
+		Time:      now,
+		Route:     strings.Join(routes, ","),
+		AlertKind: e.Kind,
+		Alert:     &alert,
+		key:       fmt.Sprintf("%v/%v/%v", alert.ID, alert.AlertHash, e.Kind),
+	}
+}
+
+// hookRunner runs the configured hooks for events. Hooks run in the
+// background, at most concurrency at a time, and each only once per event.
+type hookRunner struct {
+	hooks  []HookConfig
+	sem    chan struct{}
+	output io.Writer
+
+	mu sync.Mutex
+	// seen holds the keys of the events hooks have run for. Departure events
+	// are forgotten hookSeenGrace after their departure, the value, others
+	// have a zero time and are kept.
+	seen map[string]time.Time
+	wg   sync.WaitGroup
+}
+
+// newHookRunner returns a runner for the hooks in config. The hooks' output
+// and errors are written to output, nil discards them.
+func newHookRunner(config Config, output io.Writer) *hookRunner {
+	concurrency := config.HookConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultHookConcurrency
+	}
+
+	return &hookRunner{
+		hooks:  config.Hooks,
+		sem:    make(chan struct{}, concurrency),
+		output: output,
+		seen:   map[string]time.Time{},
+	}
+}
+
+// hookLogFilePath is where the hooks' output goes in the TUI, where it would
+// break the screen.
+func hookLogFilePath() string {
+	return configFilePath("hooks.log")
+}
+
+// newTUIHookRunner returns a runner writing the hooks' output and errors to
+// the hook log. If the log can't be opened, it's said on stdout before the
+// TUI starts and the output is discarded. The returned func waits for the
+// running hooks and closes the log.
+func newTUIHookRunner(config Config) (*hookRunner, func()) {
+	if len(config.Hooks) == 0 {
+		return newHookRunner(config, nil), func() {}
+	}
+
+	path := hookLogFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		fmt.Println(redText("can't open the hook log: " + err.Error()))
+		return newHookRunner(config, nil), func() {}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Println(redText("can't open the hook log: " + err.Error()))
+		return newHookRunner(config, nil), func() {}
+	}
+
+	h := newHookRunner(config, file)
+	return h, func() {
+		h.Wait()
+		file.Close()
+	}
+}
+
+func (h *hookRunner) matches(hook HookConfig, e Event) bool {
+	if hook.Event != e.Type {
+		return false
+	}
+
+	if len(hook.Routes) > 0 {
+		routes := strings.Split(e.Route, ",")
+		if !slices.ContainsFunc(hook.Routes, func(r string) bool {
+			return slices.ContainsFunc(routes, func(route string) bool { return strings.EqualFold(r, route) })
+		}) {
+			return false
+		}
+	}
+	if len(hook.Stops) > 0 && !slices.ContainsFunc(hook.Stops, func(s string) bool { return strings.EqualFold(s, e.StopCode) }) {
+		return false
+	}
+
+	// Departures that have already left don't fire either
+	if e.Type == eventDeparture && (*e.MinutesLeft < 0 || *e.MinutesLeft > hook.Within) {
+		return false
+	}
+
+	return true
+}
+
+// emit runs every hook that matches one of the events.
+func (h *hookRunner) emit(events ...Event) {
+	if h == nil {
+		return
+	}
+
+	h.forgetPast(time.Now())
+
+	for i, hook := range h.hooks {
+		for _, e := range events {
+			if !h.matches(hook, e) {
+				continue
+			}
+
+			if e.key != "" {
+				key := fmt.Sprintf("%v/%v", i, e.key)
+
+				h.mu.Lock()
+				departure, seen := h.seen[key]
+				// A delayed departure is remembered until it has left
+				if e.Departure != nil && e.Departure.After(departure) {
+					departure = *e.Departure
+				}
+				h.seen[key] = departure
+				h.mu.Unlock()
+
+				if seen {
+					continue
+				}
+			}
+
+			h.wg.Add(1)
+			go func() {
+				defer h.wg.Done()
+
+				h.sem <- struct{}{}
+				defer func() { <-h.sem }()
+
+				if err := h.run(hook, e); err != nil {
+					h.printf("hook %q failed: %v\n", hook.Command, err)
+				}
+			}()
+		}
+	}
+}
+
+// forgetPast drops the departure events whose departure was more than
+// hookSeenGrace ago, so long running views don't remember every departure.
+func (h *hookRunner) forgetPast(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, departure := range h.seen {
+		if !departure.IsZero() && now.Sub(departure) > hookSeenGrace {
+			delete(h.seen, key)
+		}
+	}
+}
+
+func (h *hookRunner) printf(format string, a ...any) {
+	if h.output != nil {
+		fmt.Fprintf(h.output, format, a...)
+	}
+}
+
+func (h *hookRunner) run(hook HookConfig, e Event) error {
+	timeout := defaultHookTimeout
+	if hook.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	input, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = h.output
+	cmd.Stderr = h.output
+	cmd.Env = append(os.Environ(), e.env()...)
+	// Don't wait on children the command left behind holding its output
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+
+	return err
+}
+
+// Wait blocks until all running hooks have finished.
+func (h *hookRunner) Wait() {
+	if h != nil {
+		h.wg.Wait()
+	}
+}
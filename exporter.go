@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// exportStops polls the stops and the alerts until ctx is cancelled and
+// records what it sees in m.
+func exportStops(ctx context.Context, apikey string, stops []Stop, interval time.Duration, m *transitMetrics) {
+	m.observeStops(stops, time.Now())
+
+	alertsTicker := time.NewTicker(serverAlertsInterval)
+	defer alertsTicker.Stop()
+
+	observeAlerts := func() {
+		alerts, err := getAllAlertsContext(ctx, apikey)
+		if err == nil {
+			m.observeAlerts(alerts, time.Now())
+		}
+	}
+	observeAlerts()
+
+	snapshots := watchStops(ctx, apikey, stops, interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alertsTicker.C:
+			observeAlerts()
+		case snapshot, ok := <-snapshots:
+			if !ok {
+				return
+			}
+			if snapshot.Err == nil {
+				m.observeStops(snapshot.Stops, snapshot.Fetched)
+			}
+		}
+	}
+}
+
+// exporterCommand implements hslterm exporter.
+func exporterCommand(args []string) {
+	fs := flag.NewFlagSet("exporter", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	addr := fs.String("addr", ":9090", "Address to serve /metrics on")
+	code := fs.String("code", "", "Codes of the stops to export, comma separated (default: favourite stops)")
+	interval := fs.Duration("interval", 30*time.Second, "How often the stops are polled")
+	fs.Parse(args)
+
+	key := resolveApikey(*apikey, *tempApikey)
+	config := mustLoadConfig()
+
+	codes := config.Favourites.Stops
+	if *code != "" {
+		codes = strings.Split(*code, ",")
+	}
+	if len(codes) == 0 {
+		fmt.Println(redText(tr("no stops to export, give them with -code or as favourite stops in the config file")))
+		os.Exit(1)
+	}
+
+	m := newTransitMetrics()
+	apiObserver = m.observeAPIRequest
+
+	stops, err := getStopsByCode(key, codes, 5)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	go exportStops(ctx, key, stops, *interval, m)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.write(w)
+	})
+
+	httpServer := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf(tr("serving metrics on %v")+"\n", *addr+"/metrics")
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println(redText("server failed: " + err.Error()))
+		os.Exit(1)
+	}
+}
@@ -3,20 +3,23 @@ module github.com/theshouttingparrot/hslterm
 go 1.23.4
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.5.0
+	github.com/gdamore/tcell/v2 v2.7.1
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/jedib0t/go-pretty/v6 v6.6.5
-	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d
 	github.com/rivo/tview v0.0.0-20241103174730-c76f7879f592
-	github.com/gdamore/tcell/v2 v2.7.1
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/sync v0.10.0
+	golang.org/x/term v0.22.0
 )
 
 require (
 	github.com/gdamore/encoding v1.0.0 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/term v0.17.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// language is the language of hslterm's own texts and the one asked from the
+// API for headsigns and alerts. It is "en", "fi" or "sv".
+var language = "en"
+
+var supportedLanguages = []string{"en", "fi", "sv"}
+
+// languageFromEnv picks the language from the locale like other programs do,
+// e.g. LANG=fi_FI.UTF-8 gives "fi". Unsupported locales give English.
+func languageFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+
+		code, _, _ := strings.Cut(strings.ToLower(locale), "_")
+		code, _, _ = strings.Cut(code, ".")
+		if slices.Contains(supportedLanguages, code) {
+			return code
+		}
+
+		return "en"
+	}
+
+	return "en"
+}
+
+// setLanguage sets the language from -lang.
+func setLanguage(code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if !slices.Contains(supportedLanguages, code) {
+		return fmt.Errorf("unsupported language %q, use one of %v", code, strings.Join(supportedLanguages, ", "))
+	}
+
+	language = code
+	return nil
+}
+
+// translations holds the Finnish and Swedish versions of hslterm's texts,
+// keyed by the English text. Format verbs must stay in the same order.
+var translations = map[string]map[string]string{
+	// Departures
+	"Stop: %v (%v, %v) %v %v": {"fi": "Pysäkki: %v (%v, %v) %v %v", "sv": "Hållplats: %v (%v, %v) %v %v"},
+	"Location: %v, %v":        {"fi": "Sijainti: %v, %v", "sv": "Läge: %v, %v"},
+	"Zone: %v":                {"fi": "Vyöhyke: %v", "sv": "Zon: %v"},
+	"Routes:":                 {"fi": "Linjat:", "sv": "Linjer:"},
+	"Alerts:":                 {"fi": "Häiriöt:", "sv": "Störningar:"},
+	"Route":                   {"fi": "Linja", "sv": "Linje"},
+	"Departing":               {"fi": "Lähtee", "sv": "Avgår"},
+	"Time left":               {"fi": "Aikaa", "sv": "Tid kvar"},
+	"Platform":                {"fi": "Laituri", "sv": "Plattform"},
+	"Stop":                    {"fi": "Pysäkki", "sv": "Hållplats"},
+	"CANCELED":                {"fi": "PERUTTU", "sv": "INSTÄLLD"},
+	"%v, platform %v":         {"fi": "%v, laituri %v", "sv": "%v, plattform %v"},
+	"platform %v":             {"fi": "laituri %v", "sv": "plattform %v"},
+	"%v (was %v)":             {"fi": "%v (oli %v)", "sv": "%v (var %v)"},
+	"zone %v":                 {"fi": "vyöhyke %v", "sv": "zon %v"},
+	"Now":                     {"fi": "Nyt", "sv": "Nu"},
+	"%vmin":                   {"fi": "%v min", "sv": "%v min"},
+	"%vh %vmin":               {"fi": "%v t %v min", "sv": "%v h %v min"},
+	"%v ago":                  {"fi": "%v sitten", "sv": "för %v sedan"},
+	"All departures":          {"fi": "Kaikki lähdöt", "sv": "Alla avgångar"},
+	"Loading departures...":   {"fi": "Ladataan lähtöjä...", "sv": "Laddar avgångar..."},
+	"no stops found":          {"fi": "pysäkkejä ei löytynyt", "sv": "inga hållplatser hittades"},
+
+	// Stop selection and search
+	"Select stop or press enter for all: ": {"fi": "Valitse pysäkki tai paina enter nähdäksesi kaikki: ", "sv": "Välj hållplats eller tryck enter för alla: "},
+	"invalid selection":                    {"fi": "virheellinen valinta", "sv": "ogiltigt val"},
+	"Select the stop to view":              {"fi": "Valitse näytettävä pysäkki", "sv": "Välj hållplatsen som visas"},
+	"Cancel":                               {"fi": "Peruuta", "sv": "Avbryt"},
+	"Quit":                                 {"fi": "Lopeta", "sv": "Avsluta"},
+	"Search for HSL stops":                 {"fi": "Hae HSL:n pysäkkejä", "sv": "Sök HRT:s hållplatser"},
+	"Search for stops":                     {"fi": "Hae pysäkkejä", "sv": "Sök hållplatser"},
+
+	// Key hints and status
+	"m for menu":                        {"fi": "m avaa valikon", "sv": "m öppnar menyn"},
+	"enter to see where the vehicle is": {"fi": "enter näyttää missä kulkuneuvo on", "sv": "enter visar var fordonet är"},
+	"esc to go back":                    {"fi": "esc palaa takaisin", "sv": "esc går tillbaka"},
+	"refresh failed: ":                  {"fi": "päivitys epäonnistui: ", "sv": "uppdateringen misslyckades: "},
+	"refresh failed, retrying: ":        {"fi": "päivitys epäonnistui, yritetään uudelleen: ", "sv": "uppdateringen misslyckades, försöker igen: "},
+	"failed to load stops, retrying: ":  {"fi": "pysäkkien lataus epäonnistui, yritetään uudelleen: ", "sv": "hållplatserna kunde inte laddas, försöker igen: "},
+
+	// Trip tracker
+	"Scheduled":                              {"fi": "Aikataulu", "sv": "Tidtabell"},
+	"Expected":                               {"fi": "Arvio", "sv": "Väntas"},
+	"Delay":                                  {"fi": "Myöhässä", "sv": "Försening"},
+	"on time":                                {"fi": "ajallaan", "sv": "i tid"},
+	"%v min late":                            {"fi": "%v min myöhässä", "sv": "%v min försenad"},
+	"%v min early":                           {"fi": "%v min etuajassa", "sv": "%v min före"},
+	", doors open":                           {"fi": ", ovet auki", "sv": ", dörrarna öppna"},
+	"Loading trip...":                        {"fi": "Ladataan vuoroa...", "sv": "Laddar turen..."},
+	"no trip information for this departure": {"fi": "lähdölle ei ole vuorotietoja", "sv": "ingen turinformation för avgången"},
+	"no vehicle positions: ":                 {"fi": "ei kulkuneuvojen sijainteja: ", "sv": "inga fordonspositioner: "},
+	"Vehicle %v, %.0f km/h, %v%v, seen %vs ago":                          {"fi": "Kulkuneuvo %v, %.0f km/h, %v%v, nähty %vs sitten", "sv": "Fordon %v, %.0f km/h, %v%v, sett för %vs sedan"},
+	"Waiting for the vehicle's position, showing the realtime timetable": {"fi": "Odotetaan kulkuneuvon sijaintia, näytetään reaaliaikainen aikataulu", "sv": "Väntar på fordonets position, visar tidtabellen i realtid"},
+
+	// Alerts
+	"HSL Alerts":         {"fi": "HSL:n häiriötiedotteet", "sv": "HRT:s störningsmeddelanden"},
+	"Alert":              {"fi": "Tiedote", "sv": "Meddelande"},
+	"Routes":             {"fi": "Linjat", "sv": "Linjer"},
+	"Severity":           {"fi": "Vakavuus", "sv": "Allvarlighet"},
+	"Date":               {"fi": "Aika", "sv": "Tid"},
+	"Effect":             {"fi": "Vaikutus", "sv": "Påverkan"},
+	"Link":               {"fi": "Linkki", "sv": "Länk"},
+	"No link":            {"fi": "Ei linkkiä", "sv": "Ingen länk"},
+	"Details":            {"fi": "Tiedot", "sv": "Detaljer"},
+	"Stops:":             {"fi": "Pysäkit:", "sv": "Hållplatser:"},
+	"Cause:":             {"fi": "Syy:", "sv": "Orsak:"},
+	"Effect:":            {"fi": "Vaikutus:", "sv": "Påverkan:"},
+	"HSL alert":          {"fi": "HSL:n häiriötiedote", "sv": "HRT:s störningsmeddelande"},
+	"HSL alert updated":  {"fi": "HSL:n häiriötiedote päivittyi", "sv": "HRT:s störningsmeddelande uppdaterat"},
+	"HSL alert resolved": {"fi": "HSL:n häiriö ohi", "sv": "HRT:s störning över"},
+	"saved the %v alerts in effect, only changes to them are reported": {"fi": "voimassa olevat %v tiedotetta tallennettiin, vain niiden muutokset ilmoitetaan", "sv": "de %v gällande meddelandena sparades, endast ändringar i dem rapporteras"},
+
+	// City bikes and park and ride
+	"City bikes":                  {"fi": "Kaupunkipyörät", "sv": "Stadscyklar"},
+	"Station":                     {"fi": "Asema", "sv": "Station"},
+	"Bikes":                       {"fi": "Pyöriä", "sv": "Cyklar"},
+	"Free docks":                  {"fi": "Vapaita paikkoja", "sv": "Lediga platser"},
+	"State":                       {"fi": "Tila", "sv": "Läge"},
+	"Distance":                    {"fi": "Etäisyys", "sv": "Avstånd"},
+	"Open":                        {"fi": "Avoinna", "sv": "Öppen"},
+	"Closed":                      {"fi": "Suljettu", "sv": "Stängd"},
+	"No pickups or returns":       {"fi": "Ei lainausta eikä palautusta", "sv": "Ingen utlåning eller retur"},
+	"No pickups":                  {"fi": "Ei lainausta", "sv": "Ingen utlåning"},
+	"No returns":                  {"fi": "Ei palautusta", "sv": "Ingen retur"},
+	"No realtime data":            {"fi": "Ei reaaliaikatietoa", "sv": "Ingen realtidsdata"},
+	"no bike stations found":      {"fi": "kaupunkipyöräasemia ei löytynyt", "sv": "inga cykelstationer hittades"},
+	"Facility":                    {"fi": "Pysäköintipaikka", "sv": "Parkering"},
+	"Free":                        {"fi": "Vapaana", "sv": "Lediga"},
+	"Capacity":                    {"fi": "Paikkoja", "sv": "Platser"},
+	"Full":                        {"fi": "Käytössä", "sv": "Upptaget"},
+	"Status":                      {"fi": "Tila", "sv": "Status"},
+	"open":                        {"fi": "avoinna", "sv": "öppen"},
+	"full":                        {"fi": "täynnä", "sv": "full"},
+	"closed":                      {"fi": "suljettu", "sv": "stängd"},
+	"unknown":                     {"fi": "tuntematon", "sv": "okänd"},
+	"no parking facilities found": {"fi": "pysäköintipaikkoja ei löytynyt", "sv": "inga parkeringar hittades"},
+
+	// Routes and timetables
+	"Route: %v - %v %v":  {"fi": "Linja: %v - %v %v", "sv": "Linje: %v - %v %v"},
+	"Operator: %v":       {"fi": "Liikennöitsijä: %v", "sv": "Trafikoperatör: %v"},
+	"Route info":         {"fi": "Linjan tiedot", "sv": "Linjeinformation"},
+	"Direction %v: → %v": {"fi": "Suunta %v: → %v", "sv": "Riktning %v: → %v"},
+	"Code":               {"fi": "Tunnus", "sv": "Kod"},
+	"Zone":               {"fi": "Vyöhyke", "sv": "Zon"},
+	"Timetable of %v → %v at %v (%v) on %v": {"fi": "Aikataulu %v → %v pysäkillä %v (%v) %v", "sv": "Tidtabell för %v → %v vid %v (%v) %v"},
+	"Hour":                      {"fi": "Tunti", "sv": "Timme"},
+	"Minutes":                   {"fi": "Minuutit", "sv": "Minuter"},
+	"no routes found":           {"fi": "linjoja ei löytynyt", "sv": "inga linjer hittades"},
+	"%v doesn't stop at %v":     {"fi": "%v ei pysähdy pysäkillä %v", "sv": "%v stannar inte vid %v"},
+	"the route has no patterns": {"fi": "linjalla ei ole reittejä", "sv": "linjen har inga rutter"},
+	"Directions":                {"fi": "Suunnat", "sv": "Riktningar"},
+	"Stops":                     {"fi": "Pysäkit", "sv": "Hållplatser"},
+	"Timetable":                 {"fi": "Aikataulu", "sv": "Tidtabell"},
+	"Loading...":                {"fi": "Ladataan...", "sv": "Laddar..."},
+	"No departures":             {"fi": "Ei lähtöjä", "sv": "Inga avgångar"},
+	"direction %v, %v stops":    {"fi": "suunta %v, %v pysäkkiä", "sv": "riktning %v, %v hållplatser"},
+	"tab to switch between directions and stops, enter on a stop to see its departures, q to quit": {
+		"fi": "tab vaihtaa suuntien ja pysäkkien välillä, enter pysäkillä näyttää sen lähdöt, q lopettaa",
+		"sv": "tab växlar mellan riktningar och hållplatser, enter på en hållplats visar dess avgångar, q avslutar",
+	},
+
+	// Punctuality history
+	"Departures": {"fi": "Lähtöjä", "sv": "Avgångar"},
+	"Avg delay":  {"fi": "Keskim. viive", "sv": "Medelförsening"},
+	"Median":     {"fi": "Mediaani", "sv": "Median"},
+	"Canceled":   {"fi": "Peruttu", "sv": "Inställda"},
+	"On time":    {"fi": "Ajallaan", "sv": "I tid"},
+	"no stops to record, give them with -code or as favourite stops in the config file": {"fi": "tallennettavia pysäkkejä ei ole, anna ne -code-valitsimella tai suosikkipysäkkeinä asetustiedostossa", "sv": "inga hållplatser att spara, ange dem med -code eller som favorithållplatser i inställningsfilen"},
+	"recording %v stops to %v":                         {"fi": "tallennetaan %v pysäkkiä tiedostoon %v", "sv": "sparar %v hållplatser i %v"},
+	"no departures recorded, run hslterm record first": {"fi": "lähtöjä ei ole tallennettu, aja ensin hslterm record", "sv": "inga avgångar har sparats, kör hslterm record först"},
+	"Punctuality over the last %v days":                {"fi": "Täsmällisyys viimeisten %v päivän ajalta", "sv": "Punktlighet under de senaste %v dagarna"},
+
+	// Reminders
+	"Leave now for %v %v, departing %v from %v (%v)": {"fi": "Lähde nyt: %v %v lähtee %v pysäkiltä %v (%v)", "sv": "Gå nu till %v %v, avgår %v från %v (%v)"},
+	"Time to leave": {"fi": "Aika lähteä", "sv": "Dags att gå"},
+	"No upcoming departures from %v (%v) to remind of": {"fi": "Ei muistutettavia lähtöjä pysäkiltä %v (%v)", "sv": "Inga kommande avgångar från %v (%v) att påminna om"},
+	"Next %v %v departs %v":                            {"fi": "Seuraava %v %v lähtee %v", "sv": "Nästa %v %v avgår %v"},
+	", leave at %v":                                    {"fi": ", lähde %v", "sv": ", gå %v"},
+	"remind needs a stop, give it with -code":          {"fi": "muistutus tarvitsee pysäkin, anna se -code-valitsimella", "sv": "påminnelsen behöver en hållplats, ange den med -code"},
+	"no stop with code %v":                             {"fi": "pysäkkiä tunnuksella %v ei ole", "sv": "ingen hållplats med koden %v"},
+
+	// Journeys
+	"Zones: %v, ticket: %v":          {"fi": "Vyöhykkeet: %v, lippu: %v", "sv": "Zoner: %v, biljett: %v"},
+	"Leaves":                         {"fi": "Lähtee", "sv": "Avgår"},
+	"From":                           {"fi": "Mistä", "sv": "Från"},
+	"To":                             {"fi": "Mihin", "sv": "Till"},
+	"Arrives":                        {"fi": "Perillä", "sv": "Framme"},
+	"Option %v: %v → %v (%v min)":    {"fi": "Vaihtoehto %v: %v → %v (%v min)", "sv": "Alternativ %v: %v → %v (%v min)"},
+	"Fares from the routing API: %v": {"fi": "Reittioppaan hinnat: %v", "sv": "Priser från reseplaneraren: %v"},
+
+	// Kiosk
+	"no stops given for kiosk mode": {"fi": "kioskitilalle ei annettu pysäkkejä", "sv": "inga hållplatser angavs för kioskläget"},
+
+	// Usage
+	"give a station name or a place with -near, e.g. hslterm bikes kamppi":                 {"fi": "anna aseman nimi tai paikka -near-valitsimella, esim. hslterm bikes kamppi", "sv": "ange en stations namn eller en plats med -near, t.ex. hslterm bikes kamppi"},
+	"give where to leave from and where to go, e.g. hslterm journey -from=H0040 -to=E0003": {"fi": "anna mistä lähdetään ja minne mennään, esim. hslterm journey -from=H0040 -to=E0003", "sv": "ange varifrån du åker och vart du ska, t.ex. hslterm journey -from=H0040 -to=E0003"},
+	"give the route, e.g. hslterm line 550":                                                {"fi": "anna linja, esim. hslterm line 550", "sv": "ange linjen, t.ex. hslterm line 550"},
+	"give the vehicles to follow with -route or -mode":                                     {"fi": "anna seurattavat kulkuneuvot -route- tai -mode-valitsimella", "sv": "ange fordonen som följs med -route eller -mode"},
+
+	// Servers
+	"no stops to export, give them with -code or as favourite stops in the config file": {"fi": "vietäviä pysäkkejä ei ole, anna ne -code-valitsimella tai suosikkipysäkkeinä asetustiedostossa", "sv": "inga hållplatser att exportera, ange dem med -code eller som favorithållplatser i inställningsfilen"},
+	"serving metrics on %v": {"fi": "mittarit osoitteessa %v", "sv": "mätvärden på %v"},
+	"serving on %v":         {"fi": "palvelin osoitteessa %v", "sv": "servern på %v"},
+}
+
+// tr returns s in the current language. Texts without a translation are
+// shown in English.
+func tr(s string) string {
+	if t, ok := translations[s][language]; ok {
+		return t
+	}
+
+	return s
+}
@@ -0,0 +1,69 @@
+// Package hsltime converts the times of HSL's timetables and formats them
+// the way they are shown on departure boards.
+package hsltime
+
+import (
+	"fmt"
+	"time"
+
+	// Bundles the zone database so Helsinki time works on machines without
+	// one, e.g. in containers and on Windows
+	_ "time/tzdata"
+)
+
+// Helsinki is the zone of HSL's timetables. Departure times are shown in it
+// whatever the machine's zone is.
+var Helsinki = mustLoadLocation("Europe/Helsinki")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// ServiceTime returns the time seconds after the start of a service day, as
+// given in the API's serviceDay and scheduledDeparture fields. GTFS counts
+// the seconds from noon minus 12 hours, not from midnight, so that the clock
+// times come out right on the days DST starts or ends. Trips running past
+// midnight have more than 24 hours of seconds and belong to the previous
+// service day.
+func ServiceTime(serviceDay int64, seconds int64) time.Time {
+	return time.Unix(serviceDay+seconds, 0).In(Helsinki)
+}
+
+// ServiceDate returns the date of a service day at noon in Helsinki. Noon is
+// always on the right date, even on the days DST changes.
+func ServiceDate(serviceDay int64) time.Time {
+	return time.Unix(serviceDay+12*60*60, 0).In(Helsinki)
+}
+
+// FormatClock formats t as a Helsinki wall clock time, e.g. "08:05".
+func FormatClock(t time.Time) string {
+	return t.In(Helsinki).Format("15:04")
+}
+
+// FormatRelative formats the time from now to t rounded to the minute, e.g.
+// "5min", "1h 20min" or "2min ago" for departures that have already left.
+// Under half a minute either way is "Now". The texts "Now", "%v ago",
+// "%vh %vmin" and "%vmin" are passed through tr for translation.
+func FormatRelative(t time.Time, now time.Time, tr func(string) string) string {
+	min := int(t.Sub(now).Round(time.Minute).Minutes())
+	if min == 0 {
+		return tr("Now")
+	} else if min < 0 {
+		return fmt.Sprintf(tr("%v ago"), formatMinutes(-min, tr))
+	}
+
+	return formatMinutes(min, tr)
+}
+
+func formatMinutes(min int, tr func(string) string) string {
+	if min >= 60 {
+		return fmt.Sprintf(tr("%vh %vmin"), min/60, min%60)
+	}
+
+	return fmt.Sprintf(tr("%vmin"), min)
+}
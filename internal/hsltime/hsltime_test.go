@@ -0,0 +1,99 @@
+package hsltime
+
+import (
+	"testing"
+	"time"
+)
+
+// serviceDayOf is the API's serviceDay of date: noon minus 12 hours in
+// Helsinki.
+func serviceDayOf(t *testing.T, date string) int64 {
+	t.Helper()
+
+	noon, err := time.ParseInLocation("2006-01-02 15:04", date+" 12:00", Helsinki)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return noon.Add(-12 * time.Hour).Unix()
+}
+
+func TestServiceTime(t *testing.T) {
+	// The machine's zone must not matter
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	tests := []struct {
+		name    string
+		date    string
+		seconds int64
+		clock   string
+		day     string
+	}{
+		{"morning", "2025-06-01", 8 * 60 * 60, "08:00", "2025-06-01"},
+		{"after midnight", "2025-06-01", 25*60*60 + 5*60, "01:05", "2025-06-02"},
+		{"late after midnight", "2025-06-01", 28*60*60 + 30*60, "04:30", "2025-06-02"},
+		// The service day starts at 23:00 the day before when DST starts and
+		// at 01:00 when it ends, so the early hours are an hour off midnight
+		{"DST starts, before the change", "2025-03-30", 2*60*60 + 30*60, "01:30", "2025-03-30"},
+		{"DST starts, after the change", "2025-03-30", 8 * 60 * 60, "08:00", "2025-03-30"},
+		{"DST starts, after midnight", "2025-03-30", 24*60*60 + 30*60, "00:30", "2025-03-31"},
+		{"DST ends, before the change", "2025-10-26", 2*60*60 + 30*60, "03:30", "2025-10-26"},
+		{"DST ends, after the change", "2025-10-26", 8 * 60 * 60, "08:00", "2025-10-26"},
+		{"DST ends, after midnight", "2025-10-26", 24*60*60 + 30*60, "00:30", "2025-10-27"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceDay := serviceDayOf(t, tt.date)
+			departs := ServiceTime(serviceDay, tt.seconds)
+
+			if got := FormatClock(departs); got != tt.clock {
+				t.Errorf("FormatClock() = %v, want %v", got, tt.clock)
+			}
+			if got := departs.Format("2006-01-02"); got != tt.day {
+				t.Errorf("departs on %v, want %v", got, tt.day)
+			}
+			if got := ServiceDate(serviceDay).Format("2006-01-02"); got != tt.date {
+				t.Errorf("ServiceDate() = %v, want %v", got, tt.date)
+			}
+		})
+	}
+}
+
+// untranslated leaves the texts in English.
+func untranslated(s string) string {
+	return s
+}
+
+func TestFormatRelative(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, Helsinki)
+
+	tests := []struct {
+		name string
+		in   time.Duration
+		want string
+	}{
+		{"now", 0, "Now"},
+		{"under half a minute", 29 * time.Second, "Now"},
+		{"just left", -20 * time.Second, "Now"},
+		{"minutes", 5 * time.Minute, "5min"},
+		{"rounded up", 4*time.Minute + 40*time.Second, "5min"},
+		{"59 minutes", 59 * time.Minute, "59min"},
+		{"an hour", 60 * time.Minute, "1h 0min"},
+		{"over an hour", 75 * time.Minute, "1h 15min"},
+		{"over two hours", 150 * time.Minute, "2h 30min"},
+		{"left a minute ago", -time.Minute, "1min ago"},
+		{"left minutes ago", -7 * time.Minute, "7min ago"},
+		{"left hours ago", -90 * time.Minute, "1h 30min ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRelative(now.Add(tt.in), now, untranslated); got != tt.want {
+				t.Errorf("FormatRelative(%v) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
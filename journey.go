@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// hslZones are HSL's fare zones from the centre outwards. Tickets are sold for
+// a run of neighbouring zones, e.g. AB or BCD.
+const hslZones = "ABCD"
+
+// formatZone formats a stop's zone for showing next to its name, empty if
+// the zone isn't known.
+func formatZone(zone string) string {
+	if zone == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(tr("zone %v"), zone)
+}
+
+// withZone appends the zone to a stop's name, e.g. "Kamppi (H1234, zone A)".
+func withZone(name string, code string, zone string) string {
+	if zone == "" {
+		return fmt.Sprintf("%v (%v)", name, code)
+	}
+
+	return fmt.Sprintf("%v (%v, %v)", name, code, formatZone(zone))
+}
+
+// ticketForZones returns the ticket that covers travel through all the zones,
+// e.g. "ABC". There are no single zone tickets for zones A to C, a trip
+// within one of them needs a two zone ticket. Zones outside HSL's are ignored.
+func ticketForZones(zones []string) string {
+	first, last := -1, -1
+	for _, zone := range zones {
+		if len(zone) != 1 {
+			continue
+		}
+		i := strings.Index(hslZones, zone)
+		if i < 0 {
+			continue
+		}
+
+		if first < 0 || i < first {
+			first = i
+		}
+		if i > last {
+			last = i
+		}
+	}
+
+	if first < 0 {
+		return ""
+	}
+
+	if first == last {
+		switch hslZones[first] {
+		case 'A', 'B':
+			return "AB"
+		case 'C':
+			return "BC"
+		}
+	}
+
+	return hslZones[first : last+1]
+}
+
+// itineraryZones returns the zones of every stop the itinerary's vehicles
+// stop at, in alphabetical order.
+func itineraryZones(itinerary Itinerary) []string {
+	zones := []string{}
+	add := func(place Place) {
+		if place.Stop != nil && place.Stop.ZoneID != "" && !slices.Contains(zones, place.Stop.ZoneID) {
+			zones = append(zones, place.Stop.ZoneID)
+		}
+	}
+
+	for _, leg := range itinerary.Legs {
+		if !leg.TransitLeg {
+			continue
+		}
+
+		add(leg.From)
+		for _, place := range leg.IntermediatePlaces {
+			add(place)
+		}
+		add(leg.To)
+	}
+	slices.Sort(zones)
+
+	return zones
+}
+
+// itineraryFares returns the tickets the routing API offers for the
+// itinerary's legs, each only once.
+func itineraryFares(itinerary Itinerary) []string {
+	fares := []string{}
+	for _, leg := range itinerary.Legs {
+		for _, fare := range leg.FareProducts {
+			text := fare.Product.Name
+			if fare.Product.Price != nil {
+				text += fmt.Sprintf(" %.2f %v", fare.Product.Price.Amount, fare.Product.Price.Currency.Code)
+			}
+
+			if !slices.Contains(fares, text) {
+				fares = append(fares, text)
+			}
+		}
+	}
+
+	return fares
+}
+
+func formatPlace(place Place) string {
+	if place.Stop == nil {
+		return place.Name
+	}
+
+	return withZone(place.Stop.Name, place.Stop.Code, place.Stop.ZoneID)
+}
+
+func printItinerary(i int, itinerary Itinerary) {
+	start := time.UnixMilli(itinerary.StartTime)
+	end := time.UnixMilli(itinerary.EndTime)
+
+	zones := itineraryZones(itinerary)
+	ticket := ticketForZones(zones)
+	if ticket == "" {
+		ticket = "?"
+	}
+
+	fmt.Printf("\n"+bold(tr("Option %v: %v → %v (%v min)"))+"\n", i+1, hsltime.FormatClock(start), hsltime.FormatClock(end), int(end.Sub(start).Minutes()))
+	fmt.Printf(tr("Zones: %v, ticket: %v")+"\n", strings.Join(zones, ", "), bold(ticket))
+	if fares := itineraryFares(itinerary); len(fares) > 0 {
+		fmt.Printf(tr("Fares from the routing API: %v")+"\n", strings.Join(fares, ", "))
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{tr("Leaves"), tr("Route"), tr("From"), tr("To"), tr("Arrives")})
+
+	t.SetStyle(table.StyleRounded)
+
+	for _, leg := range itinerary.Legs {
+		route := strings.ToLower(leg.Mode)
+		if leg.Route != nil {
+			route = fmt.Sprintf("%v %v", transportModeEmoji(leg.Mode), bold(leg.Route.ShortName))
+		}
+
+		t.AppendRow(table.Row{
+			hsltime.FormatClock(time.UnixMilli(leg.StartTime)),
+			route,
+			formatPlace(leg.From),
+			formatPlace(leg.To),
+			hsltime.FormatClock(time.UnixMilli(leg.EndTime)),
+		})
+	}
+
+	t.Render()
+}
+
+// journeyCommand implements hslterm journey.
+func journeyCommand(args []string) {
+	fs := flag.NewFlagSet("journey", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	from := fs.String("from", "", "Where to leave from, LAT,LON or a stop code, e.g. H0040")
+	to := fs.String("to", "", "Where to go, LAT,LON or a stop code, e.g. E0003")
+	n := fs.Int("n", 3, "Number of options to show")
+	fs.Parse(args)
+
+	if *from == "" && *to == "" && fs.NArg() == 2 {
+		*from, *to = fs.Arg(0), fs.Arg(1)
+	}
+	if *from == "" || *to == "" {
+		fmt.Println(redText(tr("give where to leave from and where to go, e.g. hslterm journey -from=H0040 -to=E0003")))
+		os.Exit(1)
+	}
+
+	key := resolveApikey(*apikey, *tempApikey)
+
+	fromLat, fromLon, err := parseNear(key, *from)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+	toLat, toLon, err := parseNear(key, *to)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	itineraries, err := getItineraries(key, fromLat, fromLon, toLat, toLon, *n)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+	if len(itineraries) == 0 {
+		fmt.Println(tr("no routes found"))
+		os.Exit(0)
+	}
+
+	for i, itinerary := range itineraries {
+		printItinerary(i, itinerary)
+	}
+}
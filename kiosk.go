@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+const (
+	defaultKioskRotate     = 15 * time.Second
+	defaultKioskExitKey    = "Ctrl+Q"
+	defaultKioskDepartures = 3
+	kioskRetryInterval     = 30 * time.Second
+)
+
+// bigFont is a five row tall block font used for the departure times in kiosk
+// mode. Only the characters needed for clock times are included.
+var bigFont = map[rune][5]string{
+	'0': {"███", "█ █", "█ █", "█ █", "███"},
+	'1': {" ██", "  █", "  █", "  █", "  █"},
+	'2': {"███", "  █", "███", "█  ", "███"},
+	'3': {"███", "  █", "███", "  █", "███"},
+	'4': {"█ █", "█ █", "███", "  █", "  █"},
+	'5': {"███", "█  ", "███", "  █", "███"},
+	'6': {"███", "█  ", "███", "█ █", "███"},
+	'7': {"███", "  █", "  █", "  █", "  █"},
+	'8': {"███", "█ █", "███", "█ █", "███"},
+	'9': {"███", "█ █", "███", "  █", "███"},
+	':': {" ", "█", " ", "█", " "},
+	' ': {" ", " ", " ", " ", " "},
+}
+
+// bigText renders s in bigFont. Characters missing from the font are skipped.
+func bigText(s string) [5]string {
+	var lines [5]string
+	for _, r := range s {
+		glyph, ok := bigFont[r]
+		if !ok {
+			continue
+		}
+		for i := range lines {
+			lines[i] += glyph[i] + " "
+		}
+	}
+
+	return lines
+}
+
+// isKeyChord reports whether event is the key chord described by name. Names
+// use tcell's notation, e.g. "Ctrl+Q", "Alt+Rune[x]" or "F10".
+func isKeyChord(event *tcell.EventKey, name string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "-", "+"))
+	}
+
+	return normalize(event.Name()) == normalize(name)
+}
+
+// kioskAlertTicker joins the alerts of all stops, each alert only once.
+func kioskAlertTicker(stops []Stop) string {
+	seen := map[string]bool{}
+	texts := []string{}
+	for _, stop := range stops {
+		for _, alert := range stop.Alerts {
+			if seen[alert.ID] {
+				continue
+			}
+			seen[alert.ID] = true
+			texts = append(texts, alert.Header(language))
+		}
+	}
+
+	return strings.Join(texts, "   ///   ")
+}
+
+// kiosk is the full-screen signage view. It ignores the keyboard except for
+// the exit chord and rotates between its stops on its own.
+type kiosk struct {
+	header *tview.TextView
+	body   *tview.TextView
+	footer *tview.TextView
+
+	config  KioskConfig
+	stops   []Stop
+	page    int
+	rotated time.Time
+	ticker  string
+	offset  int
+	status  string
+}
+
+func newKiosk(config KioskConfig) *kiosk {
+	return &kiosk{
+		header: tview.NewTextView().SetDynamicColors(true),
+		body:   tview.NewTextView().SetDynamicColors(true),
+		footer: tview.NewTextView().SetDynamicColors(true),
+		config: config,
+	}
+}
+
+func (k *kiosk) update(stops []Stop) {
+	k.stops = stops
+	k.ticker = kioskAlertTicker(stops)
+	if k.page >= len(stops) {
+		k.page = 0
+	}
+
+	k.draw()
+}
+
+// tick advances the clock, the alert ticker and the page rotation.
+func (k *kiosk) tick(rotate time.Duration) {
+	if len(k.stops) > 1 && time.Since(k.rotated) >= rotate {
+		k.page = (k.page + 1) % len(k.stops)
+		k.rotated = time.Now()
+	}
+	k.offset++
+
+	k.draw()
+}
+
+func (k *kiosk) draw() {
+	_, _, width, _ := k.footer.GetInnerRect()
+
+	k.header.Clear()
+	k.body.Clear()
+	k.footer.Clear()
+
+	clock := time.Now().In(hsltime.Helsinki).Format("15:04:05")
+
+	if len(k.stops) == 0 {
+		fmt.Fprintf(k.header, "[::b]hslterm[::-]  %v\n", clock)
+		fmt.Fprintf(k.body, "\n  %v\n", tr("Loading departures..."))
+		if k.status != "" {
+			fmt.Fprintf(k.footer, "%v%v", colorTag(theme.Warning, ""), tview.Escape(k.status))
+		}
+		return
+	}
+
+	stop := k.stops[k.page]
+	fmt.Fprintf(k.header, "[::b]%v %v[::-]  %v  %v%v\n",
+		transportModeEmoji(stop.VehicleMode), tview.Escape(withZone(stop.Name, stop.Code, stop.ZoneID)), tview.Escape(stop.Desc), colorTag(theme.Text, ""), clock)
+
+	for i, stopTime := range stop.StopTimes {
+		if i >= k.config.Departures {
+			break
+		}
+
+		departs := departureTime(stopTime)
+		lines := bigText(hsltime.FormatClock(departs))
+
+		label := fmt.Sprintf("%v %v %v", tuiRouteLabel(stopTime.Trip.Route, stopTime.Trip.RouteShortName), tview.Escape(stopTime.Headsign), wheelchairIcon(stopTime.Trip.WheelchairAccessible))
+		if stopTime.RealtimeState == "CANCELED" {
+			label = colorTag(theme.Warning, "b") + tview.Escape(stopTime.Trip.RouteShortName) + " (" + tr("CANCELED") + ")[-::-]"
+		}
+
+		for row, line := range lines {
+			fmt.Fprintf(k.body, "  %v%v[-]", colorTag(theme.Title, ""), line)
+			switch row {
+			case 1:
+				fmt.Fprintf(k.body, "  %v", label)
+			case 3:
+				fmt.Fprintf(k.body, "  %v", hsltime.FormatRelative(departs, time.Now(), tr))
+			}
+			fmt.Fprint(k.body, "\n")
+		}
+		fmt.Fprint(k.body, "\n")
+	}
+
+	if k.status != "" {
+		fmt.Fprintf(k.footer, "%v%v", colorTag(theme.Warning, ""), tview.Escape(k.status))
+	} else if k.ticker != "" {
+		text := []rune(k.ticker + "   ///   ")
+		if width < 1 {
+			width = len(text)
+		}
+
+		scrolled := make([]rune, 0, width)
+		for i := 0; i < width; i++ {
+			scrolled = append(scrolled, text[(k.offset+i)%len(text)])
+		}
+		fmt.Fprintf(k.footer, "%v%v", colorTag(theme.Warning, ""), tview.Escape(string(scrolled)))
+	}
+}
+
+// kioskLoadStops fetches the kiosk's stops, retrying until it succeeds or ctx
+// is cancelled.
+func kioskLoadStops(ctx context.Context, apikey string, codes []string, onError func(error)) ([]Stop, error) {
+	for {
+		stops, err := getStopsByCode(apikey, codes, 5)
+		if err == nil && len(stops) > 0 {
+			return stops, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no stops found for codes %v", strings.Join(codes, ","))
+		}
+		onError(err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(kioskRetryInterval):
+		}
+	}
+}
+
+// tuiDisplayKiosk runs the full-screen signage mode for the stops with the
+// given codes. API errors are shown on screen and retried, they never end the
+// program.
+func tuiDisplayKiosk(codes []string, apikey string, config KioskConfig, hooks *hookRunner) {
+	if len(codes) == 0 {
+		fmt.Println(redText(tr("no stops given for kiosk mode")))
+		os.Exit(1)
+	}
+
+	if config.ExitKey == "" {
+		config.ExitKey = defaultKioskExitKey
+	}
+	if config.Departures <= 0 {
+		config.Departures = defaultKioskDepartures
+	}
+
+	rotate := defaultKioskRotate
+	if config.Rotate != "" {
+		var err error
+		rotate, err = time.ParseDuration(config.Rotate)
+		if err != nil {
+			fmt.Println(redText("invalid kiosk rotate interval: " + err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	app := tview.NewApplication()
+	k := newKiosk(config)
+
+	// Swallow every key but the exit chord
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if isKeyChord(event, config.ExitKey) {
+			app.Stop()
+		}
+		return nil
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(k.header, 2, 0, false).
+		AddItem(k.body, 0, 1, false).
+		AddItem(k.footer, 1, 0, false)
+	layout.SetBorderPadding(1, 1, 2, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		stops, err := kioskLoadStops(ctx, apikey, codes, func(err error) {
+			app.QueueUpdateDraw(func() {
+				k.status = tr("failed to load stops, retrying: ") + err.Error()
+				k.draw()
+			})
+		})
+		if err != nil {
+			return
+		}
+
+		app.QueueUpdateDraw(func() {
+			k.status = ""
+			k.rotated = time.Now()
+			k.update(stops)
+		})
+
+		clock := time.NewTicker(time.Second)
+		defer clock.Stop()
+
+		snapshots := watchStops(ctx, apikey, stops, departuresRefreshInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.C:
+				app.QueueUpdateDraw(func() {
+					k.tick(rotate)
+				})
+			case snapshot, ok := <-snapshots:
+				if !ok {
+					return
+				}
+
+				if snapshot.Err == nil {
+					hooks.emit(stopEvents(snapshot.Stops, snapshot.Fetched)...)
+				}
+
+				app.QueueUpdateDraw(func() {
+					k.status = ""
+					if snapshot.Err != nil {
+						k.status = tr("refresh failed, retrying: ") + snapshot.Err.Error()
+					}
+					k.update(snapshot.Stops)
+				})
+			}
+		}
+	}()
+
+	k.draw()
+
+	err := app.SetRoot(layout, true).Run()
+	cancel()
+	if err != nil {
+		fmt.Println(redText("kiosk stopped: " + err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// timetableHour is the minutes of the departures within one hour.
+type timetableHour struct {
+	Hour    string
+	Minutes []string
+}
+
+// timetableHours groups departures by the hour they leave in. Departures past
+// midnight belong to the service day they started on and stay at the end.
+func timetableHours(stopTimes []StopTimes) []timetableHour {
+	hours := []timetableHour{}
+	for _, stopTime := range stopTimes {
+		departs := hsltime.ServiceTime(stopTime.ServiceDay, stopTime.ScheduledDeparture)
+		hour := departs.Format("15")
+
+		if len(hours) == 0 || hours[len(hours)-1].Hour != hour {
+			hours = append(hours, timetableHour{Hour: hour})
+		}
+		last := &hours[len(hours)-1]
+		last.Minutes = append(last.Minutes, departs.Format("04"))
+	}
+
+	return hours
+}
+
+// patternsInDirection returns the patterns of route going in direction, all
+// of them if direction is negative.
+func patternsInDirection(route RouteDetails, direction int) []Pattern {
+	patterns := []Pattern{}
+	for _, pattern := range route.Patterns {
+		if direction < 0 || pattern.DirectionID == direction {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// patternStop returns the stop of pattern with the given code.
+func patternStop(pattern Pattern, code string) (PatternStop, bool) {
+	for _, stop := range pattern.Stops {
+		if strings.EqualFold(stop.Code, code) {
+			return stop, true
+		}
+	}
+
+	return PatternStop{}, false
+}
+
+func printRoute(route RouteDetails, direction int) {
+	fmt.Printf(bold(tr("Route: %v - %v %v"))+"\n", route.ShortName, route.LongName, transportModeEmoji(route.Mode))
+	fmt.Printf(bold(tr("Operator: %v"))+"\n", hyperlink(route.Agency.Name, route.Agency.Url))
+	if route.Url != "" {
+		fmt.Println(hyperlink(tr("Route info"), route.Url))
+	}
+
+	for _, pattern := range patternsInDirection(route, direction) {
+		fmt.Printf("\n"+bold(tr("Direction %v: → %v"))+" (%v)\n", pattern.DirectionID, pattern.Headsign, pattern.Code)
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"#", tr("Code"), tr("Stop"), tr("Zone")})
+
+		t.SetStyle(table.StyleRounded)
+
+		for i, stop := range pattern.Stops {
+			t.AppendRow(table.Row{i + 1, stop.Code, fmt.Sprintf("%v (%v)", stop.Name, stop.Desc), stop.ZoneID})
+		}
+
+		t.Render()
+	}
+}
+
+func printTimetable(route RouteDetails, pattern Pattern, stop PatternStop, date time.Time, stopTimes []StopTimes) {
+	fmt.Printf("\n"+bold(tr("Timetable of %v → %v at %v (%v) on %v"))+"\n",
+		route.ShortName, pattern.Headsign, stop.Name, stop.Code, date.Format("02.01.2006"))
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{tr("Hour"), tr("Minutes")})
+
+	t.SetStyle(table.StyleRounded)
+
+	for _, hour := range timetableHours(stopTimes) {
+		t.AppendRow(table.Row{bold(hour.Hour), strings.Join(hour.Minutes, " ")})
+	}
+
+	t.Render()
+}
+
+// lineCommand implements hslterm line.
+func lineCommand(args []string) {
+	fs := flag.NewFlagSet("line", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	code := fs.String("stop", "", "Code of a stop on the route to show the day's timetable for, e.g. H0040")
+	direction := fs.Int("direction", -1, "Only show this direction, 0 or 1")
+	dateFlag := fs.String("date", "", "Date of the timetable, e.g. 2025-01-31 (default: today)")
+	tui := fs.Bool("tui", false, "Browse the route's stops in a tui view")
+
+	// Allow the route's name before the flags, e.g. hslterm line 550 -stop=H0040
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fmt.Println(redText(tr("give the route, e.g. hslterm line 550")))
+		os.Exit(1)
+	}
+
+	date := time.Now().In(hsltime.Helsinki)
+	if *dateFlag != "" {
+		var err error
+		date, err = time.ParseInLocation("2006-01-02", *dateFlag, hsltime.Helsinki)
+		if err != nil {
+			fmt.Println(redText("invalid date: " + err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	key := resolveApikey(*apikey, *tempApikey)
+
+	routes, err := getRoutes(key, name)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+	if len(routes) == 0 {
+		fmt.Println(tr("no routes found"))
+		os.Exit(0)
+	}
+
+	if *tui {
+		config := mustLoadConfig()
+		hooks, closeHooks := newTUIHookRunner(config)
+		defer closeHooks()
+		tuiDisplayLine(routes, *direction, key, date, vehicleBroker(config), hooks)
+		return
+	}
+
+	for _, route := range routes {
+		printRoute(route, *direction)
+
+		if *code == "" {
+			continue
+		}
+
+		found := false
+		for _, pattern := range patternsInDirection(route, *direction) {
+			stop, ok := patternStop(pattern, *code)
+			if !ok {
+				continue
+			}
+			found = true
+
+			stopTimes, err := getPatternTimetable(key, stop.GtfsID, pattern.Code, date.Format("20060102"))
+			if err != nil {
+				fmt.Println(redText("got err " + err.Error()))
+				os.Exit(1)
+			}
+
+			printTimetable(route, pattern, stop, date, stopTimes)
+		}
+
+		if !found {
+			fmt.Printf("\n"+tr("%v doesn't stop at %v")+"\n", route.ShortName, *code)
+		}
+	}
+}
+
+// tuiDisplayLine shows the route's patterns and stops. Selecting a stop shows
+// its timetable for date, enter opens the stop's departure board.
+func tuiDisplayLine(routes []RouteDetails, direction int, apikey string, date time.Time, broker string, hooks *hookRunner) {
+	// The directions of all the routes are listed together, each knowing its
+	// route for the header
+	type routePattern struct {
+		route   RouteDetails
+		pattern Pattern
+	}
+	all := []routePattern{}
+	for _, route := range routes {
+		for _, pattern := range patternsInDirection(route, direction) {
+			all = append(all, routePattern{route, pattern})
+		}
+	}
+	if len(all) == 0 {
+		fmt.Println(tr("the route has no patterns"))
+		os.Exit(0)
+	}
+
+	app := tview.NewApplication()
+
+	patterns := tview.NewList().ShowSecondaryText(true)
+	patterns.SetBorder(true).SetTitle(" " + tr("Directions") + " ")
+
+	stops := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	stops.SetBorder(true).SetTitle(" " + tr("Stops") + " ")
+
+	timetable := tview.NewTextView().SetDynamicColors(true)
+	timetable.SetBorder(true).SetTitle(" " + tr("Timetable") + " ")
+
+	current := all[0].pattern
+	timetables := map[string]string{}
+	openStop := ""
+
+	// showTimetable loads the timetable in the background, the stop may no
+	// longer be selected once it arrives.
+	showTimetable := func(pattern Pattern, stop PatternStop) {
+		key := pattern.Code + "/" + stop.GtfsID
+		if text, ok := timetables[key]; ok {
+			timetable.SetText(text)
+			return
+		}
+		timetable.SetText(tr("Loading..."))
+
+		go func() {
+			stopTimes, err := getPatternTimetable(apikey, stop.GtfsID, pattern.Code, date.Format("20060102"))
+
+			var text strings.Builder
+			if err != nil {
+				fmt.Fprintf(&text, "%v%v", colorTag(theme.Warning, ""), tview.Escape(err.Error()))
+			} else {
+				fmt.Fprintf(&text, "[::b]%v (%v)[::-]\n%v\n\n", tview.Escape(stop.Name), stop.Code, date.Format("02.01.2006"))
+				for _, hour := range timetableHours(stopTimes) {
+					fmt.Fprintf(&text, "[::b]%v[::-]  %v\n", hour.Hour, strings.Join(hour.Minutes, " "))
+				}
+				if len(stopTimes) == 0 {
+					text.WriteString(tr("No departures"))
+				}
+			}
+
+			app.QueueUpdateDraw(func() {
+				if err == nil {
+					timetables[key] = text.String()
+				}
+
+				row, _ := stops.GetSelection()
+				if current.Code == pattern.Code && row >= 1 && row <= len(current.Stops) && current.Stops[row-1].GtfsID == stop.GtfsID {
+					timetable.SetText(text.String())
+				}
+			})
+		}()
+	}
+
+	showPattern := func(pattern Pattern) {
+		current = pattern
+
+		stops.Clear()
+		for col, title := range []string{"#", tr("Code"), tr("Stop"), tr("Zone")} {
+			stops.SetCell(0, col, tview.NewTableCell(title).SetSelectable(false).SetAttributes(tcell.AttrBold))
+		}
+		for i, stop := range pattern.Stops {
+			stops.SetCellSimple(i+1, 0, fmt.Sprint(i+1))
+			stops.SetCellSimple(i+1, 1, stop.Code)
+			stops.SetCell(i+1, 2, tview.NewTableCell(fmt.Sprintf("%v (%v)", stop.Name, stop.Desc)).SetExpansion(1))
+			stops.SetCellSimple(i+1, 3, stop.ZoneID)
+		}
+		stops.ScrollToBeginning()
+		stops.Select(1, 0)
+
+		if len(pattern.Stops) > 0 {
+			showTimetable(pattern, pattern.Stops[0])
+		}
+	}
+
+	stops.SetSelectionChangedFunc(func(row int, _ int) {
+		if row >= 1 && row <= len(current.Stops) {
+			showTimetable(current, current.Stops[row-1])
+		}
+	})
+	stops.SetSelectedFunc(func(row int, _ int) {
+		if row >= 1 && row <= len(current.Stops) {
+			openStop = current.Stops[row-1].Code
+			app.Stop()
+		}
+	})
+
+	for _, p := range all {
+		name := fmt.Sprintf("→ %v", p.pattern.Headsign)
+		if len(routes) > 1 {
+			name = p.route.ShortName + " " + name
+		}
+		patterns.AddItem(
+			name,
+			fmt.Sprintf(tr("direction %v, %v stops"), p.pattern.DirectionID, len(p.pattern.Stops)),
+			0,
+			func() { app.SetFocus(stops) },
+		)
+	}
+
+	layout := tview.NewFlex().
+		AddItem(patterns, 0, 1, true).
+		AddItem(stops, 0, 2, false).
+		AddItem(timetable, 0, 1, false)
+
+	frame := tview.NewFrame(layout).SetBorders(1, 1, 1, 1, 2, 2)
+	showRoute := func(route RouteDetails) {
+		frame.Clear().
+			AddText("hslterm", true, tview.AlignLeft, themeColor(theme.Text)).
+			AddText(fmt.Sprintf("%v %v - %v", transportModeEmoji(route.Mode), route.ShortName, route.LongName), true, tview.AlignCenter, themeColor(theme.Text)).
+			AddText(route.Agency.Name, true, tview.AlignCenter, themeColor(theme.Info)).
+			AddText(tr("tab to switch between directions and stops, enter on a stop to see its departures, q to quit"), false, tview.AlignCenter, themeColor(theme.Hint))
+	}
+
+	patterns.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		showRoute(all[index].route)
+		showPattern(all[index].pattern)
+	})
+	showRoute(all[0].route)
+	showPattern(current)
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRune:
+			if event.Rune() == 'q' {
+				app.Stop()
+			}
+		case tcell.KeyTab, tcell.KeyBacktab:
+			if patterns.HasFocus() {
+				app.SetFocus(stops)
+			} else {
+				app.SetFocus(patterns)
+			}
+			return nil
+		case tcell.KeyEsc:
+			app.Stop()
+		}
+
+		return event
+	})
+
+	if err := app.SetRoot(frame, true).Run(); err != nil {
+		panic(err)
+	}
+
+	if openStop != "" {
+		found, err := getStopsByCode(apikey, []string{openStop}, 5)
+		if err != nil {
+			fmt.Println(redText("got err " + err.Error()))
+			os.Exit(1)
+		}
+
+		tuiDisplayStops(found, apikey, broker, hooks)
+	}
+}
@@ -7,15 +7,12 @@ import (
 	"os"
 	"strings"
 	"syscall"
-	"time"
 	"unsafe"
-
-	"github.com/mgutz/ansi"
 )
 
 const appName = "hslterm"
 
-const usageText = "hslterm usage:\n\thslterm [OPTIONS]\n\nhslterm lets you see a HSL stop's timetables that update in realtime\n" +
+const usageText = "hslterm usage:\n\thslterm [OPTIONS]\n\thslterm COMMAND [OPTIONS]\n\nhslterm lets you see a HSL stop's timetables that update in realtime\n" +
 	"Also let's you see a map of the Helsinki metro that updates locations of metros in realtime\n" +
 	"\nhslterm requires users to give an api key for https://digitransit.fi/\n" +
 	"\t-apikey=APIKEY sets the apikey. Stores it in ~/.config/hslterm/apikey.txt\n" +
@@ -25,11 +22,36 @@ const usageText = "hslterm usage:\n\thslterm [OPTIONS]\n\nhslterm lets you see a
 	"\t-stop=[NAME OF STOP]: displays the timetable for the next hour, if multiple stops have the same name, will ask user to specify\n" +
 	"\t-code=[CODE OF STOP]: specify the code of the stop so no need to specify later (with comma separation you may enter multiple codes)\n" +
 	"\t-a: displays/prints all stops and doesn't ask to specify\n" +
-	"\t-alerts: prints list of alerts\n" +
+	"\t-alerts: prints list of alerts, most severe first. Filter them with:\n" +
+	"\t\t-severity=SEVERE,WARNING: only alerts with these severities\n" +
+	"\t\t-effect=[EFFECTS]: only alerts with these effects (e.g. REDUCED_SERVICE,DETOUR)\n" +
+	"\t\t-cause=[CAUSES]: only alerts with these causes (e.g. CONSTRUCTION,STRIKE)\n" +
+	"\t\t-route=[ROUTE]: only alerts affecting the route (e.g. 550)\n" +
+	"\t\t-stop=[CODE OF STOP]: only alerts affecting the stop\n" +
+	"\t\t-active-now: only alerts that are in effect right now\n" +
 	"\t-metro: displays the metro map in terminal (-tui option enabled automatically) [COMING SOON]" +
 	"\t-tui: shows the given data in a live updating tui view\n" +
+	"\t-dashboard: shows several stops side by side, the stops are given with -code or in ~/.config/hslterm/config.json\n" +
+	"\t-merged: with -dashboard, shows the departures of all stops on one board\n" +
+	"\t-columns=N: with -dashboard, the number of boards side by side (default: fit to terminal)\n" +
+	"\t-kiosk: full-screen signage mode for the stops given with -code or in the config file, only the exit chord (default Ctrl+Q) is accepted\n" +
+	"\t-lang=en|fi|sv: language of the texts, alerts and headsigns (default from $LANG)\n" +
+	"\t-theme=NAME: colour theme, default, high-contrast, monochrome, hsl or one from the config file\n" +
+	"\t-accessible-only: hides departures that can't be boarded with a wheelchair\n" +
 	"\t-api: print current apikey\n" +
-	"\t-h/-help: shows this"
+	"\t-h/-help: shows this\n" +
+	"\nSubcommands (see hslterm COMMAND -h)\n" +
+	"\talerts [-watch]: prints alerts, with -watch keeps polling them and reports new, changed and resolved alerts\n" +
+	"\tremind -code=[CODE OF STOP] [-route=ROUTES] [-walk=DURATION]: tells you when to leave to catch the next departure\n" +
+	"\tserve [-addr=:8080]: serves departures and alerts as JSON over HTTP\n" +
+	"\texporter [-addr=:9090] [-code=CODES]: exports punctuality metrics for Prometheus\n" +
+	"\trecord [-code=CODES]: records departures to a local database for stats\n" +
+	"\tstats [-route=ROUTES] [-days=N]: shows how punctual the recorded routes have been\n" +
+	"\tvehicles [-route=ROUTES] [-mode=MODES]: follows realtime vehicle positions\n" +
+	"\tline ROUTE [-stop=CODE] [-tui]: shows a route's stops in each direction and a stop's timetable for the day\n" +
+	"\tbikes [NAME] [-near=LAT,LON|CODE] [-tui]: shows the bikes and free docks at city bike stations\n" +
+	"\tparking [NAME] [-near=LAT,LON|CODE] [-short]: shows the free spaces at park and ride facilities\n" +
+	"\tjourney -from=PLACE -to=PLACE: plans a journey and tells which zones it crosses and which ticket you need"
 
 func getTerminalWidth() (int, error) {
 	var ws struct {
@@ -53,12 +75,25 @@ func getTerminalWidth() (int, error) {
 }
 
 func hyperlink(text, url string) string {
-	if url == "" {
+	if url == "" || !useANSI {
 		return text
 	}
 	return fmt.Sprintf("\033]8;;%s\033\\%s\033]8;;\033\\", url, text)
 }
 
+// splitFlagList splits a comma separated flag value into upper case items.
+func splitFlagList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func usageFn(s string) error {
 	fmt.Println(usageText)
 
@@ -67,19 +102,6 @@ func usageFn(s string) error {
 	return nil
 }
 
-func formatTimeLeft(t int64) string {
-	min := int(time.Until(time.Unix(t, 0)).Round(time.Minute).Minutes())
-	if min < 1 {
-		return "Now"
-	} else if min > 120 {
-		return fmt.Sprintf("%vh %vmin", min/60)
-	} else if min > 60 {
-		return fmt.Sprintf("%vh %vmin", min/60, min%60)
-	}
-
-	return fmt.Sprintf("%vmin", min)
-}
-
 func transportModeEmoji(mode string) string {
 	switch mode {
 	case "BUS":
@@ -97,8 +119,18 @@ func transportModeEmoji(mode string) string {
 	return "❓"
 }
 
-var bold = ansi.ColorFunc("white+b")
-var redText = ansi.ColorFunc("red+b")
+// wheelchairIcon shows whether a stop or departure can be boarded with a
+// wheelchair. It's empty when that isn't known.
+func wheelchairIcon(boarding string) string {
+	switch boarding {
+	case "POSSIBLE":
+		return "♿"
+	case "NOT_POSSIBLE":
+		return "🚫♿"
+	}
+
+	return ""
+}
 
 func main() {
 	flag.BoolFunc("help", "usage", usageFn)
@@ -116,36 +148,60 @@ func main() {
 		return nil
 	})
 
-	apikey := flag.String("apikey", "", "Sets the API key. Stores it in ~/.config/hslterm/apikey.txt")
-	tempApikey := flag.String("temp-apikey", "", "Sets a temporary API key for the duration of one command")
+	language = languageFromEnv()
+	loadThemes()
+
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	apikey, tempApikey := addApikeyFlags(flag.CommandLine)
 	stop := flag.String("stop", "", "Displays the timetable for the next hour for the given stop")
 	code := flag.String("code", "", "Specify the code of the stop to avoid asking later")
 	metro := flag.Bool("metro", false, "Displays the metro map in terminal (Enables -tui automatically)")
 	alerts := flag.Bool("alerts", false, "prints list of alerts")
 	tui := flag.Bool("tui", false, "Shows the given data in a live updating TUI view")
 	printAll := flag.Bool("a", false, "Displays/prints all stops and doesn't ask to specify")
+	dashboard := flag.Bool("dashboard", false, "Shows several stops side by side")
+	merged := flag.Bool("merged", false, "Shows the departures of all dashboard stops on one board")
+	columns := flag.Int("columns", 0, "Number of boards side by side in the dashboard")
+	kiosk := flag.Bool("kiosk", false, "Full-screen signage mode")
+	severity := flag.String("severity", "", "Only shows alerts with the given severities, e.g. SEVERE,WARNING")
+	effect := flag.String("effect", "", "Only shows alerts with the given effects, e.g. REDUCED_SERVICE")
+	cause := flag.String("cause", "", "Only shows alerts with the given causes, e.g. CONSTRUCTION")
+	route := flag.String("route", "", "Only shows alerts affecting the given route, e.g. 550")
+	activeNow := flag.Bool("active-now", false, "Only shows alerts that are in effect right now")
+	flag.BoolVar(&accessibleOnly, "accessible-only", false, "Hides departures that can't be boarded with a wheelchair")
 
 	flag.Parse()
 
-	if *tempApikey != "" {
-		apikey = tempApikey
-	} else if *apikey == "" {
-		var err error
+	*apikey = resolveApikey(*apikey, *tempApikey)
+	config := mustLoadConfig()
+	hooks, closeHooks := newTUIHookRunner(config)
+	defer closeHooks()
 
-		*apikey, err = loadApikey()
-		if err != nil {
-			fmt.Println(redText("error when loading apikey: " + err.Error()))
-			os.Exit(1)
-		}
-	} else {
-		err := saveApiKey(*apikey)
-		if err != nil {
-			fmt.Println(redText("failed to save api key: " + err.Error()))
-			os.Exit(1)
-		}
+	dashboardConfig := config.Dashboard
+	dashboardConfig.Merged = dashboardConfig.Merged || *merged
+	if *columns > 0 {
+		dashboardConfig.Columns = *columns
 	}
 
-	if *stop != "" {
+	if *alerts {
+		filter := alertFilter{
+			Severities: splitFlagList(*severity),
+			Effects:    splitFlagList(*effect),
+			Causes:     splitFlagList(*cause),
+			Routes:     splitFlagList(*route),
+			Stops:      splitFlagList(*stop),
+			ActiveNow:  *activeNow,
+		}
+		showAlerts(*apikey, filter, *tui)
+
+		return
+	} else if *stop != "" {
 		// Get stop data
 		stops, err := getStopData(*apikey, *stop, 5)
 		if err != nil {
@@ -153,6 +209,8 @@ func main() {
 			os.Exit(1)
 		}
 
+		groupStationPlatforms(stops)
+
 		if *code != "" {
 			*code = strings.ToUpper(*code)
 			// separate codes by comma
@@ -181,8 +239,14 @@ func main() {
 			}
 		}
 
+		if *dashboard {
+			tuiDisplayDashboard(stops, *apikey, dashboardConfig, hooks)
+
+			return
+		}
+
 		if *tui {
-			tuiDisplayStops(stops, *apikey)
+			tuiDisplayStops(stops, *apikey, vehicleBroker(config), hooks)
 
 			return
 		}
@@ -202,10 +266,10 @@ func main() {
 
 		// Select stops to show
 		for i, stop := range stops {
-			fmt.Printf("%v) %v (%v, %v) %v\n", i, stop.Name, stop.Desc, stop.Code, transportModeEmoji(stop.VehicleMode))
+			fmt.Printf("%v) %v (%v, %v) %v %v\n", i, stopLabel(stop), stop.Desc, stop.Code, formatZone(stop.ZoneID), transportModeEmoji(stop.VehicleMode))
 		}
 
-		fmt.Print("Select stop or press enter for all: ")
+		fmt.Print(tr("Select stop or press enter for all: "))
 		reader = bufio.NewReader(os.Stdin)
 		selection, err = reader.ReadString('\n')
 		if err != nil {
@@ -220,7 +284,7 @@ func main() {
 			// parse selection and show stop
 			i := int([]rune(selection)[0] - '0')
 			if i < 0 || i >= len(stops) {
-				fmt.Println(redText("invalid selection"))
+				fmt.Println(redText(tr("invalid selection")))
 				os.Exit(1)
 			}
 
@@ -235,26 +299,34 @@ func main() {
 		}
 
 		return
-	} else if *metro {
-		fmt.Println("Coming soon")
+	} else if *kiosk {
+		codes := config.Kiosk.Stops
+		if *code != "" {
+			codes = strings.Split(*code, ",")
+		}
+
+		tuiDisplayKiosk(codes, *apikey, config.Kiosk, hooks)
+
 		return
-	} else if *alerts {
-		data, err := getAllAlerts(*apikey)
+	} else if *dashboard {
+		codes := dashboardConfig.Stops
+		if *code != "" {
+			codes = strings.Split(*code, ",")
+		}
+
+		stops, err := getStopsByCode(*apikey, codes, 5)
 		if err != nil {
 			fmt.Println(redText("got err " + err.Error()))
 			os.Exit(1)
 		}
 
-		if *tui {
-			tuiDisplayAlerts(data)
-
-			return
-		}
-
-		printAlerts(data)
+		tuiDisplayDashboard(stops, *apikey, dashboardConfig, hooks)
 
+		return
+	} else if *metro {
+		fmt.Println("Coming soon")
 		return
 	}
 
-	tuiDisplaySearch(*apikey)
+	tuiDisplaySearch(*apikey, vehicleBroker(config), hooks)
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets of the histograms, in seconds
+var (
+	delayBuckets   = []float64{-120, -60, 0, 60, 120, 180, 300, 600, 900, 1800}
+	latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// labels formats label pairs, e.g. labels("stop", "H0040") gives
+// stop="H0040".
+func labels(pairs ...string) string {
+	parts := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%v=%v", pairs[i], strconv.Quote(pairs[i+1])))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// observedDeparture is a departure that is still on the board. Its delay is
+// only counted once it has left so that every departure is counted once.
+type observedDeparture struct {
+	stop     string
+	route    string
+	departs  time.Time
+	delay    float64
+	canceled bool
+	// scheduled is set when there's no realtime data of the departure, its
+	// delay is then always 0 and isn't counted
+	scheduled bool
+}
+
+// transitMetrics collects the exporter's metrics and writes them in the
+// Prometheus text format.
+type transitMetrics struct {
+	mu sync.Mutex
+
+	departures    map[string]*observedDeparture
+	delays        map[string]*histogram
+	currentDelay  map[string]float64
+	cancellations map[string]uint64
+	alerts        map[string]int
+	apiRequests   map[string]uint64
+	apiLatency    *histogram
+}
+
+func newTransitMetrics() *transitMetrics {
+	return &transitMetrics{
+		departures:    map[string]*observedDeparture{},
+		delays:        map[string]*histogram{},
+		currentDelay:  map[string]float64{},
+		cancellations: map[string]uint64{},
+		alerts:        map[string]int{},
+		apiRequests:   map[string]uint64{},
+		apiLatency:    newHistogram(latencyBuckets),
+	}
+}
+
+// observeStops records the departures of the stops at time now.
+func (m *transitMetrics) observeStops(stops []Stop, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Routes with no departures on the board anymore drop out of the gauge
+	clear(m.currentDelay)
+
+	seen := map[string]bool{}
+	for _, stop := range stops {
+		next := map[string]bool{}
+
+		for _, stopTime := range stop.StopTimes {
+			key := stop.Code + "/" + departureKey(stopTime)
+			seen[key] = true
+
+			route := stopTime.Trip.RouteShortName
+			delay := float64(stopTime.RealtimeDeparture - stopTime.ScheduledDeparture)
+			canceled := stopTime.RealtimeState == "CANCELED"
+			scheduled := stopTime.RealtimeState == "SCHEDULED"
+
+			d, ok := m.departures[key]
+			if !ok {
+				d = &observedDeparture{stop: stop.Code, route: route}
+				m.departures[key] = d
+			}
+			if canceled && !d.canceled {
+				m.cancellations[labels("stop", stop.Code, "route", route)]++
+			}
+			d.departs = departureTime(stopTime)
+			d.delay = delay
+			d.canceled = canceled
+			d.scheduled = scheduled
+
+			routeLabels := labels("stop", stop.Code, "route", route)
+			if !next[routeLabels] && !canceled {
+				m.currentDelay[routeLabels] = delay
+				next[routeLabels] = true
+			}
+		}
+	}
+
+	// A departure that has left or dropped off the board is done, its last
+	// known delay is final
+	for key, d := range m.departures {
+		if seen[key] && d.departs.After(now) {
+			continue
+		}
+
+		if !d.canceled && !d.scheduled {
+			l := labels("stop", d.stop, "route", d.route)
+			if m.delays[l] == nil {
+				m.delays[l] = newHistogram(delayBuckets)
+			}
+			m.delays[l].observe(d.delay)
+		}
+		delete(m.departures, key)
+	}
+}
+
+// observeAlerts records the number of alerts in effect at time now by
+// severity.
+func (m *transitMetrics) observeAlerts(alerts []Alert, now time.Time) {
+	active := filterAlerts(alerts, alertFilter{ActiveNow: true}, now)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for severity := range m.alerts {
+		m.alerts[severity] = 0
+	}
+	for _, alert := range active {
+		m.alerts[alert.AlertSeverityLevel]++
+	}
+}
+
+// observeAPIRequest records one request to the Digitransit API.
+func (m *transitMetrics) observeAPIRequest(duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.apiRequests[labels("result", result)]++
+	m.apiLatency.observe(duration.Seconds())
+}
+
+func writeHistogram(w io.Writer, name string, l string, h *histogram) {
+	prefix := ""
+	if l != "" {
+		prefix = l + ","
+	}
+
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%v_bucket{%vle=%q} %v\n", name, prefix, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%v_bucket{%vle=\"+Inf\"} %v\n", name, prefix, h.count)
+
+	if l != "" {
+		l = "{" + l + "}"
+	}
+	fmt.Fprintf(w, "%v_sum%v %v\n", name, l, h.sum)
+	fmt.Fprintf(w, "%v_count%v %v\n", name, l, h.count)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *transitMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP hslterm_departure_delay_seconds Delay of departures that have left, realtime minus scheduled.")
+	fmt.Fprintln(w, "# TYPE hslterm_departure_delay_seconds histogram")
+	for _, l := range sortedKeys(m.delays) {
+		writeHistogram(w, "hslterm_departure_delay_seconds", l, m.delays[l])
+	}
+
+	fmt.Fprintln(w, "# HELP hslterm_next_departure_delay_seconds Delay of the next departure of each route.")
+	fmt.Fprintln(w, "# TYPE hslterm_next_departure_delay_seconds gauge")
+	for _, l := range sortedKeys(m.currentDelay) {
+		fmt.Fprintf(w, "hslterm_next_departure_delay_seconds{%v} %v\n", l, m.currentDelay[l])
+	}
+
+	fmt.Fprintln(w, "# HELP hslterm_cancellations_total Canceled departures.")
+	fmt.Fprintln(w, "# TYPE hslterm_cancellations_total counter")
+	for _, l := range sortedKeys(m.cancellations) {
+		fmt.Fprintf(w, "hslterm_cancellations_total{%v} %v\n", l, m.cancellations[l])
+	}
+
+	fmt.Fprintln(w, "# HELP hslterm_active_alerts Alerts in effect by severity.")
+	fmt.Fprintln(w, "# TYPE hslterm_active_alerts gauge")
+	for _, severity := range sortedKeys(m.alerts) {
+		fmt.Fprintf(w, "hslterm_active_alerts{%v} %v\n", labels("severity", severity), m.alerts[severity])
+	}
+
+	fmt.Fprintln(w, "# HELP hslterm_api_requests_total Requests to the Digitransit API by result.")
+	fmt.Fprintln(w, "# TYPE hslterm_api_requests_total counter")
+	for _, l := range sortedKeys(m.apiRequests) {
+		fmt.Fprintf(w, "hslterm_api_requests_total{%v} %v\n", l, m.apiRequests[l])
+	}
+
+	fmt.Fprintln(w, "# HELP hslterm_api_request_duration_seconds Latency of requests to the Digitransit API.")
+	fmt.Fprintln(w, "# TYPE hslterm_api_request_duration_seconds histogram")
+	writeHistogram(w, "hslterm_api_request_duration_seconds", "", m.apiLatency)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// metricsFixtures are the stop's responses to consecutive polls. On the first
+// 550 is late and 551 has no realtime data, on the second both have left and
+// only 552 is on the board.
+var metricsFixtures = []string{
+	`{"data": {"stop": {"gtfsId": "HSL:1040602", "code": "H0040", "name": "Kamppi", "stoptimesWithoutPatterns": [
+		{"headsign": "Espoon keskus", "realtimeState": "UPDATED", "scheduledDeparture": 600, "realtimeDeparture": 660, "serviceDay": %[1]v, "trip": {"routeShortName": "550"}},
+		{"headsign": "Westendinasema", "realtimeState": "SCHEDULED", "scheduledDeparture": 900, "realtimeDeparture": 900, "serviceDay": %[1]v, "trip": {"routeShortName": "551"}}]}}}`,
+	`{"data": {"stop": {"gtfsId": "HSL:1040602", "code": "H0040", "name": "Kamppi", "stoptimesWithoutPatterns": [
+		{"headsign": "Itäkeskus", "realtimeState": "UPDATED", "scheduledDeparture": 1200, "realtimeDeparture": 1230, "serviceDay": %[1]v, "trip": {"routeShortName": "552"}}]}}}`,
+}
+
+func TestExportStopsMetrics(t *testing.T) {
+	serviceDay := time.Now().Unix()
+
+	var mu sync.Mutex
+	polls := 0
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "query { alerts") {
+			w.Write([]byte(`{"data": {"alerts": []}}`))
+			return
+		}
+
+		mu.Lock()
+		fixture := metricsFixtures[min(polls, len(metricsFixtures)-1)]
+		polls++
+		mu.Unlock()
+
+		fmt.Fprintf(w, fixture, serviceDay)
+	})
+
+	m := newTransitMetrics()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		exportStops(ctx, "key", []Stop{{GtfsID: "HSL:1040602", Code: "H0040"}}, 10*time.Millisecond, m)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	want := []string{
+		`hslterm_departure_delay_seconds_count{stop="H0040",route="550"} 1`,
+		`hslterm_departure_delay_seconds_sum{stop="H0040",route="550"} 60`,
+		`hslterm_next_departure_delay_seconds{stop="H0040",route="552"} 30`,
+	}
+	unwanted := []string{
+		// 551 had no realtime data, its delay is unknown
+		`route="551"`,
+		// 550 has left, it has no next departure
+		`hslterm_next_departure_delay_seconds{stop="H0040",route="550"}`,
+	}
+
+	var out bytes.Buffer
+	deadline := time.Now().Add(time.Second)
+	for {
+		out.Reset()
+		m.write(&out)
+		if strings.Contains(out.String(), want[2]) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, line := range want {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("metrics don't have %v, got:\n%v", line, out.String())
+		}
+	}
+	for _, s := range unwanted {
+		if strings.Contains(out.String(), s) {
+			t.Errorf("metrics have %v, got:\n%v", s, out.String())
+		}
+	}
+}
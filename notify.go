@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Urgency levels of the freedesktop notification spec
+const (
+	urgencyLow      byte = 0
+	urgencyNormal   byte = 1
+	urgencyCritical byte = 2
+)
+
+// notifier sends freedesktop desktop notifications over the D-Bus session
+// bus.
+type notifier struct {
+	conn *dbus.Conn
+}
+
+// newNotifier connects to the session bus found through
+// $DBUS_SESSION_BUS_ADDRESS.
+func newNotifier() (*notifier, error) {
+	return dialNotifier("")
+}
+
+// dialNotifier connects to the bus at address, e.g. a test bus, or to the
+// session bus if it's empty.
+func dialNotifier(address string) (*notifier, error) {
+	var conn *dbus.Conn
+	var err error
+	if address == "" {
+		conn, err = dbus.ConnectSessionBus()
+	} else {
+		conn, err = dbus.Connect(address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &notifier{conn: conn}, nil
+}
+
+// notify shows a notification with the given summary, body and urgency.
+func (n *notifier) notify(summary string, body string, urgency byte) error {
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+
+	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
+		appName,    // app_name
+		uint32(0),  // replaces_id
+		"",         // app_icon
+		summary,    // summary
+		body,       // body
+		[]string{}, // actions
+		map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)},
+		int32(-1), // expire_timeout, -1 lets the server decide
+	)
+
+	return call.Err
+}
+
+func (n *notifier) Close() error {
+	return n.conn.Close()
+}
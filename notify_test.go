@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeNotifications is a notification server recording the notifications it
+// gets.
+type fakeNotifications struct {
+	got chan []any
+}
+
+func (f fakeNotifications) Notify(appName string, replacesID uint32, appIcon string, summary string, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	f.got <- []any{appName, summary, body, hints["urgency"].Value()}
+	return 1, nil
+}
+
+// testSessionBus starts a private session bus and returns its address.
+func testSessionBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+func TestNotifyAlertEvent(t *testing.T) {
+	address := testSessionBus(t)
+
+	server, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	fake := fakeNotifications{got: make(chan []any, 1)}
+	if err := server.Export(fake, "/org/freedesktop/Notifications", "org.freedesktop.Notifications"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RequestName("org.freedesktop.Notifications", dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := dialNotifier(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	language = "en"
+	e := alertEvent{Kind: alertChanged, Alert: Alert{
+		AlertHeaderText:    "Tram 4 diverted",
+		AlertSeverityLevel: "SEVERE",
+		Entities:           []AlertEntity{{Typename: "Route", ShortName: "4"}},
+	}}
+	if err := notifyAlertEvent(n, e); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-fake.got:
+		want := []any{appName, "HSL alert updated: 4", "Tram 4 diverted", urgencyCritical}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got notification %v, want %v", got, want)
+				break
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no notification within a second")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// nearbyParking is a park and ride facility with its distance in metres from
+// where the user searched, or -1 if they searched by name.
+type nearbyParking struct {
+	VehicleParking
+	Distance float64
+}
+
+// parkingSpaces returns the capacity and free spaces for cars, or bicycles if
+// bicycles is set. free is -1 when it isn't known.
+func parkingSpaces(p VehicleParking, bicycles bool) (capacity int, free int) {
+	capacity, free = p.Capacity.CarSpaces, -1
+	if bicycles {
+		capacity = p.Capacity.BicycleSpaces
+	}
+
+	if p.Availability != nil {
+		free = p.Availability.CarSpaces
+		if bicycles {
+			free = p.Availability.BicycleSpaces
+		}
+	}
+
+	return capacity, free
+}
+
+// parkingStatus is a one word status of a facility: "open", "full",
+// "closed" or "unknown" when it doesn't report its free spaces. It's in
+// English for scripts, show it with tr.
+func parkingStatus(p VehicleParking, bicycles bool) string {
+	if p.State != "" && p.State != "OPERATIONAL" {
+		return "closed"
+	}
+
+	_, free := parkingSpaces(p, bicycles)
+	switch {
+	case free < 0:
+		return "unknown"
+	case free == 0:
+		return "full"
+	}
+
+	return "open"
+}
+
+// findParkings returns the facilities with spaces for cars, or bicycles, that
+// match search. The nearest come first when searching by location.
+func findParkings(parkings []VehicleParking, search placeSearch, bicycles bool) []nearbyParking {
+	found := []nearbyParking{}
+	for _, p := range parkings {
+		if (bicycles && !p.BicyclePlaces) || (!bicycles && !p.CarPlaces) {
+			continue
+		}
+
+		if distance, ok := search.match(p.Name, p.Lat, p.Lon); ok {
+			found = append(found, nearbyParking{VehicleParking: p, Distance: distance})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if search.Near {
+			return found[i].Distance < found[j].Distance
+		}
+		return found[i].Name < found[j].Name
+	})
+
+	return found
+}
+
+func printParkings(parkings []nearbyParking, near bool, bicycles bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{tr("Facility"), tr("Free"), tr("Capacity"), tr("Full"), tr("Status")}
+	if near {
+		header = append(header, tr("Distance"))
+	}
+	t.AppendHeader(header)
+
+	t.SetStyle(table.StyleRounded)
+
+	for _, p := range parkings {
+		capacity, free := parkingSpaces(p.VehicleParking, bicycles)
+
+		freeText, fullText := "?", "?"
+		if free >= 0 {
+			freeText = fmt.Sprint(free)
+			if capacity > 0 {
+				fullText = fmt.Sprintf("%.0f%%", 100*float64(capacity-free)/float64(capacity))
+			}
+		}
+
+		status := parkingStatus(p.VehicleParking, bicycles)
+		if status == "full" || status == "closed" {
+			freeText = redText(freeText)
+			status = redText(tr(status))
+		} else {
+			status = tr(status)
+		}
+
+		row := table.Row{bold(p.Name), freeText, capacity, fullText, status}
+		if near {
+			row = append(row, formatDistance(p.Distance))
+		}
+		t.AppendRow(row)
+	}
+
+	t.Render()
+}
+
+// printParkingsShort prints one tab separated line per facility: id, name,
+// free spaces (-1 if unknown), capacity and status. Meant for scripts.
+func printParkingsShort(parkings []nearbyParking, bicycles bool) {
+	for _, p := range parkings {
+		capacity, free := parkingSpaces(p.VehicleParking, bicycles)
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", p.VehicleParkingID, p.Name, free, capacity, parkingStatus(p.VehicleParking, bicycles))
+	}
+}
+
+// parkingCommand implements hslterm parking.
+func parkingCommand(args []string) {
+	fs := flag.NewFlagSet("parking", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	near := fs.String("near", "", "Find facilities near a place, LAT,LON or a stop code, e.g. E0003")
+	radius := fs.Float64("radius", 1000, "With -near, how far to look in metres")
+	n := fs.Int("n", 10, "Maximum number of facilities to show")
+	bicycles := fs.Bool("bikes", false, "Show bicycle parking instead of car parking")
+	short := fs.Bool("short", false, "Print one tab separated line per facility: id, name, free spaces, capacity and status")
+
+	// Allow the name before the flags, e.g. hslterm parking kivenlahti -short
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" {
+		name = strings.Join(fs.Args(), " ")
+	}
+
+	key := resolveApikey(*apikey, *tempApikey)
+
+	search := placeSearch{Name: name, Radius: *radius}
+	if *near != "" {
+		var err error
+		search.Lat, search.Lon, err = parseNear(key, *near)
+		if err != nil {
+			fmt.Println(redText("got err " + err.Error()))
+			os.Exit(1)
+		}
+		search.Near = true
+	}
+
+	all, err := getVehicleParkings(key)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	parkings := findParkings(all, search, *bicycles)
+	if len(parkings) > *n {
+		parkings = parkings[:*n]
+	}
+
+	if *short {
+		printParkingsShort(parkings, *bicycles)
+		return
+	}
+
+	if len(parkings) == 0 {
+		fmt.Println(tr("no parking facilities found"))
+		os.Exit(0)
+	}
+
+	printParkings(parkings, search.Near, *bicycles)
+}
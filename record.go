@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+	bolt "go.etcd.io/bbolt"
+)
+
+var departuresBucket = []byte("departures")
+
+// departureObservation is the realtime state of a departure at one point in
+// time.
+type departureObservation struct {
+	Seen     int64  `json:"seen"`
+	Realtime int64  `json:"realtime"`
+	State    string `json:"state"`
+}
+
+// departureRecord is everything recorded about one departure from one stop.
+// A new observation is only added when the realtime data changes.
+type departureRecord struct {
+	Stop         string                 `json:"stop"`
+	Route        string                 `json:"route"`
+	Headsign     string                 `json:"headsign"`
+	ServiceDay   int64                  `json:"serviceDay"`
+	Scheduled    int64                  `json:"scheduled"`
+	Observations []departureObservation `json:"observations"`
+}
+
+func (r departureRecord) last() departureObservation {
+	return r.Observations[len(r.Observations)-1]
+}
+
+// delay is the last known delay of the departure in seconds.
+func (r departureRecord) delay() int64 {
+	return r.last().Realtime - r.Scheduled
+}
+
+func (r departureRecord) canceled() bool {
+	return r.last().State == "CANCELED"
+}
+
+// scheduled tells whether there was never realtime data of the departure,
+// its realtime departure is then just the scheduled one.
+func (r departureRecord) scheduled() bool {
+	return r.last().State == "SCHEDULED"
+}
+
+func historyFilePath() string {
+	return configFilePath("history.db")
+}
+
+func openHistory(path string, readOnly bool) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second, ReadOnly: readOnly})
+}
+
+// recordStops stores the departures of the stops as seen at time seen in the
+// database at path. The database is only open for the write, so hslterm stats
+// can read it while the recorder is running.
+func recordStops(path string, stops []Stop, seen time.Time) error {
+	db, err := openHistory(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(departuresBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, stop := range stops {
+			for _, stopTime := range stop.StopTimes {
+				key := []byte(stop.Code + "/" + departureKey(stopTime))
+
+				record := departureRecord{
+					Stop:       stop.Code,
+					Route:      stopTime.Trip.RouteShortName,
+					Headsign:   stopTime.Headsign,
+					ServiceDay: stopTime.ServiceDay,
+					Scheduled:  stopTime.ScheduledDeparture,
+				}
+				if data := bucket.Get(key); data != nil {
+					if err := json.Unmarshal(data, &record); err != nil {
+						return err
+					}
+				}
+
+				observation := departureObservation{
+					Seen:     seen.Unix(),
+					Realtime: stopTime.RealtimeDeparture,
+					State:    stopTime.RealtimeState,
+				}
+				if len(record.Observations) > 0 {
+					last := record.last()
+					if last.Realtime == observation.Realtime && last.State == observation.State {
+						continue
+					}
+				}
+				record.Observations = append(record.Observations, observation)
+
+				data, err := json.Marshal(record)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(key, data); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// recordCommand implements hslterm record.
+func recordCommand(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	code := fs.String("code", "", "Codes of the stops to record, comma separated (default: favourite stops)")
+	interval := fs.Duration("interval", 30*time.Second, "How often the stops are polled")
+	dbPath := fs.String("db", historyFilePath(), "Database file the departures are recorded to")
+	fs.Parse(args)
+
+	key := resolveApikey(*apikey, *tempApikey)
+	config := mustLoadConfig()
+
+	codes := config.Favourites.Stops
+	if *code != "" {
+		codes = strings.Split(*code, ",")
+	}
+	if len(codes) == 0 {
+		fmt.Println(redText(tr("no stops to record, give them with -code or as favourite stops in the config file")))
+		os.Exit(1)
+	}
+
+	stops, err := getStopsByCode(key, codes, 5)
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := recordStops(*dbPath, stops, time.Now()); err != nil {
+		fmt.Println(redText("failed to record departures: " + err.Error()))
+		os.Exit(1)
+	}
+	fmt.Printf(tr("recording %v stops to %v")+"\n", len(stops), *dbPath)
+
+	for snapshot := range watchStops(ctx, key, stops, *interval) {
+		if snapshot.Err != nil {
+			fmt.Println(redText("failed to refresh departures: " + snapshot.Err.Error()))
+			continue
+		}
+
+		if err := recordStops(*dbPath, snapshot.Stops, snapshot.Fetched); err != nil {
+			fmt.Println(redText("failed to record departures: " + err.Error()))
+		}
+	}
+}
+
+// departureStats are the statistics of a group of recorded departures.
+type departureStats struct {
+	Route    string
+	Hour     int
+	Count    int
+	Canceled int
+	// Realtime is the number of departures that left with realtime data, the
+	// ones Delays are of. Departures that only ever had their schedule are
+	// counted in Count alone.
+	Realtime int
+	Delays   []int64
+}
+
+func (s departureStats) averageDelay() float64 {
+	if len(s.Delays) == 0 {
+		return 0
+	}
+
+	var sum int64
+	for _, delay := range s.Delays {
+		sum += delay
+	}
+
+	return float64(sum) / float64(len(s.Delays))
+}
+
+// percentile returns the p:th percentile of the delays. Delays must be
+// sorted.
+func (s departureStats) percentile(p float64) int64 {
+	if len(s.Delays) == 0 {
+		return 0
+	}
+
+	i := int(p / 100 * float64(len(s.Delays)-1))
+	return s.Delays[i]
+}
+
+// onTime returns the share of the departures with realtime data that weren't
+// canceled and left at most a minute late.
+func (s departureStats) onTime() float64 {
+	known := s.Realtime + s.Canceled
+	if known == 0 {
+		return 0
+	}
+
+	n := 0
+	for _, delay := range s.Delays {
+		if delay <= 60 {
+			n++
+		}
+	}
+
+	return float64(n) / float64(known)
+}
+
+// historyStats groups the departures that left between since and until by
+// route and the hour of their scheduled departure. Only departures from the
+// given stops and routes are counted, all if empty.
+func historyStats(db *bolt.DB, since time.Time, until time.Time, stops []string, routes []string) ([]departureStats, error) {
+	groups := map[string]*departureStats{}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(departuresBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var record departureRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if len(record.Observations) == 0 {
+				return nil
+			}
+
+			if len(stops) > 0 && !slices.ContainsFunc(stops, func(s string) bool { return strings.EqualFold(s, record.Stop) }) {
+				return nil
+			}
+			if len(routes) > 0 && !slices.ContainsFunc(routes, func(r string) bool { return strings.EqualFold(r, record.Route) }) {
+				return nil
+			}
+
+			scheduled := hsltime.ServiceTime(record.ServiceDay, record.Scheduled)
+			if scheduled.Before(since) || scheduled.After(until) {
+				return nil
+			}
+
+			groupKey := fmt.Sprintf("%v/%02d", record.Route, scheduled.Hour())
+			group, ok := groups[groupKey]
+			if !ok {
+				group = &departureStats{Route: record.Route, Hour: scheduled.Hour()}
+				groups[groupKey] = group
+			}
+
+			group.Count++
+			if record.canceled() {
+				group.Canceled++
+			} else if !record.scheduled() {
+				group.Realtime++
+				group.Delays = append(group.Delays, record.delay())
+			}
+
+			return nil
+		})
+	})
+
+	stats := []departureStats{}
+	for _, group := range groups {
+		sort.Slice(group.Delays, func(i, j int) bool { return group.Delays[i] < group.Delays[j] })
+		stats = append(stats, *group)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Route != stats[j].Route {
+			return stats[i].Route < stats[j].Route
+		}
+		return stats[i].Hour < stats[j].Hour
+	})
+
+	return stats, err
+}
+
+func formatDelay(seconds float64) string {
+	return fmt.Sprintf("%+.1fmin", seconds/60)
+}
+
+func printStats(stats []departureStats) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{tr("Route"), tr("Hour"), tr("Departures"), tr("Avg delay"), tr("Median"), "90%", "95%", tr("Canceled"), tr("On time")})
+
+	t.SetStyle(table.StyleRounded)
+
+	for _, s := range stats {
+		t.AppendRow(table.Row{
+			bold(s.Route),
+			fmt.Sprintf("%02d:00", s.Hour),
+			s.Count,
+			formatDelay(s.averageDelay()),
+			formatDelay(float64(s.percentile(50))),
+			formatDelay(float64(s.percentile(90))),
+			formatDelay(float64(s.percentile(95))),
+			fmt.Sprintf("%.1f%%", 100*float64(s.Canceled)/float64(s.Count)),
+			fmt.Sprintf("%.1f%%", 100*s.onTime()),
+		})
+	}
+
+	t.Render()
+}
+
+// statsCommand implements hslterm stats.
+func statsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	addDisplayFlags(fs)
+	dbPath := fs.String("db", historyFilePath(), "Database file recorded by hslterm record")
+	days := fs.Int("days", 30, "How many days back to look")
+	code := fs.String("code", "", "Only departures from these stops, comma separated")
+	route := fs.String("route", "", "Only these routes, comma separated")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*dbPath); os.IsNotExist(err) {
+		fmt.Println(tr("no departures recorded, run hslterm record first"))
+		return
+	}
+
+	db, err := openHistory(*dbPath, true)
+	if err != nil {
+		fmt.Println(redText("failed to open database: " + err.Error()))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	stats, err := historyStats(db, now.AddDate(0, 0, -*days), now, splitFlagList(*code), splitFlagList(*route))
+	if err != nil {
+		fmt.Println(redText("failed to read database: " + err.Error()))
+		os.Exit(1)
+	}
+
+	if len(stats) == 0 {
+		fmt.Println(tr("no departures recorded, run hslterm record first"))
+		return
+	}
+
+	fmt.Println(bold(fmt.Sprintf(tr("Punctuality over the last %v days"), *days)))
+	printStats(stats)
+}
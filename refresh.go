@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// departuresRefreshInterval is how often the tui views fetch fresh data.
+const departuresRefreshInterval = 20 * time.Second
+
+// stopSnapshot is the state of a set of stops after one refresh. Snapshots are
+// never modified after they have been published so they can be handed from the
+// refresh worker to the UI goroutine without locking.
+type stopSnapshot struct {
+	Stops   []Stop
+	Err     error
+	Fetched time.Time
+}
+
+// watchStops refreshes stops every interval in its own goroutine and publishes
+// the results on the returned channel. If a refresh fails the snapshot carries
+// the error and the previous stop data. The channel is closed once ctx is
+// cancelled.
+func watchStops(ctx context.Context, apikey string, stops []Stop, interval time.Duration) <-chan stopSnapshot {
+	snapshots := make(chan stopSnapshot)
+
+	go func() {
+		defer close(snapshots)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		current := stops
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			updated, err := updateStopData(ctx, current, apikey)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				current = updated
+			}
+
+			select {
+			case snapshots <- stopSnapshot{Stops: current, Err: err, Fetched: time.Now()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return snapshots
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const fakeStopResponse = `{"data": {"stop": {"gtfsId": "HSL:1040602", "code": "H0040", "name": "Kamppi", "vehicleMode": "BUS",
+	"stoptimesWithoutPatterns": [{"headsign": "Espoon keskus", "realtimeState": "UPDATED", "scheduledDeparture": 36000, "realtimeDeparture": 36060, "serviceDay": 1735682400,
+	"trip": {"gtfsId": "HSL:1550_20250101_Ke_1_1000", "routeShortName": "550"}}]}}}`
+
+// fakeAPI points apiEndpoint at a test server answering with handler until
+// the test ends.
+func fakeAPI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	endpoint := apiEndpoint
+	apiEndpoint = server.URL
+	t.Cleanup(func() {
+		apiEndpoint = endpoint
+		server.Close()
+	})
+}
+
+// stopWatching cancels the watch and waits for the worker to close the
+// channel, so it doesn't outlive the fake API.
+func stopWatching(t *testing.T, cancel context.CancelFunc, snapshots <-chan stopSnapshot) {
+	t.Helper()
+
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-snapshots:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("snapshot channel not closed after cancel")
+		}
+	}
+}
+
+func nextSnapshot(t *testing.T, snapshots <-chan stopSnapshot) stopSnapshot {
+	t.Helper()
+
+	select {
+	case snapshot, ok := <-snapshots:
+		if !ok {
+			t.Fatal("snapshot channel closed")
+		}
+		return snapshot
+	case <-time.After(time.Second):
+		t.Fatal("no snapshot within a second")
+	}
+
+	return stopSnapshot{}
+}
+
+func TestWatchStopsDeliversSnapshots(t *testing.T) {
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeStopResponse))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := watchStops(ctx, "key", []Stop{{GtfsID: "HSL:1040602", Name: "old"}}, 10*time.Millisecond)
+	defer stopWatching(t, cancel, snapshots)
+
+	for i := 0; i < 2; i++ {
+		snapshot := nextSnapshot(t, snapshots)
+		if snapshot.Err != nil {
+			t.Fatalf("snapshot %v: unexpected error %v", i, snapshot.Err)
+		}
+		if len(snapshot.Stops) != 1 || snapshot.Stops[0].Name != "Kamppi" {
+			t.Fatalf("snapshot %v: got stops %+v, want Kamppi", i, snapshot.Stops)
+		}
+		if len(snapshot.Stops[0].StopTimes) != 1 || snapshot.Stops[0].StopTimes[0].Trip.RouteShortName != "550" {
+			t.Fatalf("snapshot %v: got departures %+v, want one of 550", i, snapshot.Stops[0].StopTimes)
+		}
+	}
+}
+
+func TestWatchStopsKeepsDataOnError(t *testing.T) {
+	failing := make(chan bool, 1)
+	failing <- false
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fail := <-failing
+		failing <- fail
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(fakeStopResponse))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := watchStops(ctx, "key", []Stop{{GtfsID: "HSL:1040602", Name: "old"}}, 10*time.Millisecond)
+	defer stopWatching(t, cancel, snapshots)
+
+	if snapshot := nextSnapshot(t, snapshots); snapshot.Err != nil || snapshot.Stops[0].Name != "Kamppi" {
+		t.Fatalf("got %+v, %v, want Kamppi without error", snapshot.Stops, snapshot.Err)
+	}
+
+	<-failing
+	failing <- true
+
+	// A refresh may have been in flight when the API started failing
+	for i := 0; i < 3; i++ {
+		snapshot := nextSnapshot(t, snapshots)
+		if snapshot.Err == nil {
+			continue
+		}
+		if len(snapshot.Stops) != 1 || snapshot.Stops[0].Name != "Kamppi" {
+			t.Fatalf("got stops %+v after a failed refresh, want the previous data", snapshot.Stops)
+		}
+		return
+	}
+	t.Fatal("no snapshot with an error")
+}
+
+func TestWatchStopsClosesOnCancel(t *testing.T) {
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeStopResponse))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := watchStops(ctx, "key", []Stop{{GtfsID: "HSL:1040602"}}, 10*time.Millisecond)
+	nextSnapshot(t, snapshots)
+
+	stopWatching(t, cancel, snapshots)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// remindGrace is how late a reminder may still fire, e.g. after the computer
+// was suspended over the time to leave.
+const remindGrace = time.Minute
+
+func departureTime(stopTime StopTimes) time.Time {
+	return hsltime.ServiceTime(stopTime.ServiceDay, stopTime.RealtimeDeparture)
+}
+
+// departureKey identifies a departure across refreshes, its realtime
+// departure time changes but the scheduled one doesn't.
+func departureKey(stopTime StopTimes) string {
+	return fmt.Sprintf("%v/%v/%v", stopTime.Trip.RouteShortName, stopTime.ServiceDay, stopTime.ScheduledDeparture)
+}
+
+// remindDepartures is how many departures remind fetches. The next departure
+// of a filtered route may be behind many others at a busy stop, so more are
+// fetched then.
+func remindDepartures(routes []string) int {
+	if len(routes) > 0 {
+		return 30
+	}
+
+	return 5
+}
+
+// nextCatchable returns the first departure of one of routes (any route if
+// empty) that can still be caught when walking to the stop takes walk.
+// Canceled departures and the ones in skip are ignored.
+func nextCatchable(stop Stop, routes []string, walk time.Duration, now time.Time, skip map[string]bool) (StopTimes, bool) {
+	for _, stopTime := range stop.StopTimes {
+		if stopTime.RealtimeState == "CANCELED" || skip[departureKey(stopTime)] {
+			continue
+		}
+		if len(routes) > 0 && !slices.ContainsFunc(routes, func(r string) bool {
+			return strings.EqualFold(r, stopTime.Trip.RouteShortName)
+		}) {
+			continue
+		}
+		if departureTime(stopTime).Add(-walk).Before(now.Add(-remindGrace)) {
+			continue
+		}
+
+		return stopTime, true
+	}
+
+	return StopTimes{}, false
+}
+
+// reminder fires the configured notifications when it's time to leave.
+type reminder struct {
+	bell  bool
+	dbus  *notifier
+	hooks *hookRunner
+}
+
+func (r reminder) fire(stop Stop, stopTime StopTimes, leave time.Time) {
+	message := fmt.Sprintf(tr("Leave now for %v %v, departing %v from %v (%v)"),
+		stopTime.Trip.RouteShortName, stopTime.Headsign,
+		hsltime.FormatClock(departureTime(stopTime)), stop.Name, stop.Code)
+
+	fmt.Println(bold(message))
+
+	if r.bell {
+		fmt.Print("\a")
+	}
+
+	if r.dbus != nil {
+		err := r.dbus.notify(tr("Time to leave"), message, urgencyCritical)
+		if err != nil {
+			fmt.Println(redText("failed to send notification: " + err.Error()))
+		}
+	}
+
+	now := time.Now()
+	departs := departureTime(stopTime)
+	minutesLeft := int(departs.Sub(now).Minutes())
+	r.hooks.emit(Event{
+		Type:        eventLeave,
+		Time:        now,
+		StopCode:    stop.Code,
+		StopName:    stop.Name,
+		Route:       stopTime.Trip.RouteShortName,
+		Headsign:    stopTime.Headsign,
+		Departure:   &departs,
+		MinutesLeft: &minutesLeft,
+		LeaveAt:     &leave,
+	})
+}
+
+// remind watches the stop's departures and fires r when it's time to leave
+// for the next matching one. The departures are refreshed every interval so
+// delays move the reminder. With repeat it goes on to the next departure,
+// otherwise it returns after the first reminder.
+func remind(ctx context.Context, apikey string, stop Stop, routes []string, walk time.Duration, interval time.Duration, repeat bool, r reminder) {
+	fired := map[string]bool{}
+	lastStatus := ""
+
+	for {
+		r.hooks.emit(stopEvents([]Stop{stop}, time.Now())...)
+
+		stopTime, ok := nextCatchable(stop, routes, walk, time.Now(), fired)
+
+		wait := interval
+		if !ok {
+			status := fmt.Sprintf(tr("No upcoming departures from %v (%v) to remind of"), stop.Name, stop.Code)
+			if status != lastStatus {
+				fmt.Println(status)
+				lastStatus = status
+			}
+		} else {
+			leave := departureTime(stopTime).Add(-walk)
+
+			if time.Until(leave) <= 0 {
+				r.fire(stop, stopTime, leave)
+				if !repeat {
+					return
+				}
+
+				fired[departureKey(stopTime)] = true
+				continue
+			}
+
+			delay := time.Duration(stopTime.RealtimeDeparture-stopTime.ScheduledDeparture) * time.Second
+			status := fmt.Sprintf(tr("Next %v %v departs %v"), stopTime.Trip.RouteShortName, stopTime.Headsign,
+				hsltime.FormatClock(departureTime(stopTime)))
+			if delay != 0 {
+				status += fmt.Sprintf(" (%+dmin)", int(delay.Round(time.Minute).Minutes()))
+			}
+			status += fmt.Sprintf(tr(", leave at %v"), leave.Format("15:04:05"))
+
+			if status != lastStatus {
+				fmt.Println(status)
+				lastStatus = status
+			}
+
+			wait = min(time.Until(leave), interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if wait == interval {
+			refreshed := stop
+			err := refreshed.refresh(ctx, apikey, remindDepartures(routes))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Println(redText("failed to refresh departures: " + err.Error()))
+			} else {
+				stop = refreshed
+			}
+		}
+	}
+}
+
+// remindCommand implements hslterm remind.
+func remindCommand(args []string) {
+	fs := flag.NewFlagSet("remind", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	code := fs.String("code", "", "Code of the stop to leave for, e.g. H0040")
+	route := fs.String("route", "", "Only remind of these routes, e.g. M1,M2")
+	walk := fs.Duration("walk", 0, "How long it takes to get to the stop, e.g. 6m")
+	interval := fs.Duration("interval", 30*time.Second, "How often the departures are refreshed")
+	repeat := fs.Bool("repeat", false, "Keeps reminding of the following departures")
+	bell := fs.Bool("bell", true, "Rings the terminal bell when it's time to leave")
+	notify := fs.Bool("notify", false, "Sends a desktop notification over D-Bus when it's time to leave")
+	hook := fs.String("hook", "", "Shell command to run when it's time to leave, details are in $HSLTERM_* variables and as JSON on stdin")
+	fs.Parse(args)
+
+	if *code == "" {
+		fmt.Println(redText(tr("remind needs a stop, give it with -code")))
+		os.Exit(1)
+	}
+
+	key := resolveApikey(*apikey, *tempApikey)
+	config := mustLoadConfig()
+
+	routes := splitFlagList(*route)
+	stops, err := getStopsByCode(key, []string{*code}, remindDepartures(routes))
+	if err != nil {
+		fmt.Println(redText("got err " + err.Error()))
+		os.Exit(1)
+	}
+	if len(stops) == 0 {
+		fmt.Println(redText(fmt.Sprintf(tr("no stop with code %v"), *code)))
+		os.Exit(1)
+	}
+
+	if *hook != "" {
+		config.Hooks = append(config.Hooks, HookConfig{Event: eventLeave, Command: *hook})
+	}
+
+	r := reminder{bell: *bell, hooks: newHookRunner(config, os.Stdout)}
+	defer r.hooks.Wait()
+	if *notify {
+		r.dbus, err = newNotifier()
+		if err != nil {
+			fmt.Println(redText("failed to connect to D-Bus: " + err.Error()))
+			os.Exit(1)
+		}
+		defer r.dbus.Close()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	remind(ctx, key, stops[0], routes, *walk, *interval, *repeat, r)
+}
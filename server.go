@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// serverStopIdle is how long a stop is kept up to date after it was last
+	// asked for.
+	serverStopIdle = 10 * time.Minute
+	// serverAlertsInterval is how often the alerts are polled.
+	serverAlertsInterval = time.Minute
+	// serverKeepalive is how often an idle stream gets a comment so proxies
+	// don't close it.
+	serverKeepalive = 30 * time.Second
+	// alertStreamBuffer is how many alert changes a slow stream client may fall
+	// behind before changes are dropped for it.
+	alertStreamBuffer = 64
+)
+
+var errStopNotFound = errors.New("stop not found")
+
+// server serves the data hslterm fetches as JSON. All clients share one
+// cache that is kept up to date by a single polling loop, so the number of
+// upstream requests doesn't grow with the number of clients and the api key
+// never leaves the machine running the server.
+type server struct {
+	apikey   string
+	interval time.Duration
+
+	mu         sync.RWMutex
+	stops      map[string]Stop
+	lastUsed   map[string]time.Time
+	alerts     []Alert
+	alertsAt   time.Time
+	refreshed  time.Time
+	alertState alertWatchState
+	// stopErrs has the error of the last refresh of each stop that failed,
+	// by stop code, and alertsErr that of the alerts. A failed stop keeps its
+	// previous departures.
+	stopErrs  map[string]error
+	alertsErr error
+
+	// Streams subscribed to a stop's departures, by stop code, and to alert
+	// changes.
+	stopSubs  map[string]map[chan Stop]bool
+	alertSubs map[chan alertEvent]bool
+
+	// fetchMu makes concurrent requests for a stop that isn't cached yet wait
+	// for one upstream request instead of each making their own, alertsFetch
+	// does the same for the alerts.
+	fetchMu     sync.Mutex
+	alertsFetch singleflight.Group
+}
+
+func newServer(apikey string, interval time.Duration) *server {
+	return &server{
+		apikey:    apikey,
+		interval:  interval,
+		stops:     map[string]Stop{},
+		lastUsed:  map[string]time.Time{},
+		stopErrs:  map[string]error{},
+		stopSubs:  map[string]map[chan Stop]bool{},
+		alertSubs: map[chan alertEvent]bool{},
+	}
+}
+
+// stop returns the cached stop with the given code, fetching it the first
+// time it is asked for. From then on it is refreshed by the polling loop.
+func (s *server) stop(code string) (Stop, error) {
+	code = strings.ToUpper(code)
+
+	s.mu.Lock()
+	stop, ok := s.stops[code]
+	if ok {
+		s.lastUsed[code] = time.Now()
+	}
+	s.mu.Unlock()
+	if ok {
+		return stop, nil
+	}
+
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.RLock()
+	stop, ok = s.stops[code]
+	s.mu.RUnlock()
+	if ok {
+		return stop, nil
+	}
+
+	stops, err := getStopsByCode(s.apikey, []string{code}, 5)
+	if err != nil {
+		return Stop{}, err
+	}
+	if len(stops) == 0 {
+		return Stop{}, errStopNotFound
+	}
+
+	s.mu.Lock()
+	s.stops[code] = stops[0]
+	s.lastUsed[code] = time.Now()
+	s.mu.Unlock()
+
+	return stops[0], nil
+}
+
+// currentAlerts returns the cached alerts, fetching them if the polling loop
+// hasn't done so yet.
+func (s *server) currentAlerts(ctx context.Context) ([]Alert, error) {
+	s.mu.RLock()
+	alerts, fetched := s.alerts, !s.alertsAt.IsZero()
+	s.mu.RUnlock()
+	if fetched {
+		return alerts, nil
+	}
+
+	// Requests arriving before the first fetch share it. It isn't cancelled
+	// with the request that started it, the others may still be waiting.
+	shared, err, _ := s.alertsFetch.Do("alerts", func() (any, error) {
+		return s.refreshAlerts(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shared.([]Alert), nil
+}
+
+func (s *server) refreshAlerts(ctx context.Context) ([]Alert, error) {
+	alerts, err := getAllAlertsContext(ctx, s.apikey)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.mu.Lock()
+			s.alertsErr = err
+			s.mu.Unlock()
+		}
+		return nil, err
+	}
+	sortAlerts(alerts)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alertsErr = nil
+
+	events, state := diffAlerts(s.alertState, alerts)
+	// The first fetch isn't a change, everything would be reported as new
+	if !s.alertsAt.IsZero() {
+		for _, e := range events {
+			for sub := range s.alertSubs {
+				select {
+				case sub <- e:
+				default:
+				}
+			}
+		}
+	}
+
+	s.alerts = alerts
+	s.alertsAt = time.Now()
+	s.alertState = state
+
+	return alerts, nil
+}
+
+// refreshStops refreshes every stop that has been asked for recently and
+// forgets the rest. Each stop is refreshed on its own, so one failing stop
+// doesn't keep the others from updating.
+func (s *server) refreshStops(ctx context.Context) {
+	s.mu.Lock()
+	stops := []Stop{}
+	for code, stop := range s.stops {
+		if time.Since(s.lastUsed[code]) > serverStopIdle && len(s.stopSubs[code]) == 0 {
+			delete(s.stops, code)
+			delete(s.lastUsed, code)
+			delete(s.stopErrs, code)
+			continue
+		}
+		stops = append(stops, stop)
+	}
+	s.mu.Unlock()
+
+	for _, stop := range stops {
+		code := stop.Code
+		err := stop.refresh(ctx, s.apikey, 5)
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.mu.Lock()
+		// The stop may have gone idle while it was being refreshed
+		if _, ok := s.stops[code]; ok {
+			if err != nil {
+				s.stopErrs[code] = err
+			} else {
+				delete(s.stopErrs, code)
+				s.stops[code] = stop
+				s.publishStop(stop)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.refreshed = time.Now()
+	s.mu.Unlock()
+}
+
+// publishStop sends stop to its streams. s.mu must be held. A stream that
+// hasn't taken the previous snapshot yet gets only the newest one.
+func (s *server) publishStop(stop Stop) {
+	for sub := range s.stopSubs[stop.Code] {
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- stop
+	}
+}
+
+func (s *server) subscribeStop(code string) chan Stop {
+	sub := make(chan Stop, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopSubs[code] == nil {
+		s.stopSubs[code] = map[chan Stop]bool{}
+	}
+	s.stopSubs[code][sub] = true
+
+	return sub
+}
+
+func (s *server) unsubscribeStop(code string, sub chan Stop) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.stopSubs[code], sub)
+	if len(s.stopSubs[code]) == 0 {
+		delete(s.stopSubs, code)
+	}
+	// The stop stays cached for serverStopIdle after the stream ends
+	s.lastUsed[code] = time.Now()
+}
+
+func (s *server) subscribeAlerts() chan alertEvent {
+	sub := make(chan alertEvent, alertStreamBuffer)
+
+	s.mu.Lock()
+	s.alertSubs[sub] = true
+	s.mu.Unlock()
+
+	return sub
+}
+
+func (s *server) unsubscribeAlerts(sub chan alertEvent) {
+	s.mu.Lock()
+	delete(s.alertSubs, sub)
+	s.mu.Unlock()
+}
+
+// poll keeps the cache up to date until ctx is cancelled.
+func (s *server) poll(ctx context.Context) {
+	stopsTicker := time.NewTicker(s.interval)
+	defer stopsTicker.Stop()
+	alertsTicker := time.NewTicker(serverAlertsInterval)
+	defer alertsTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopsTicker.C:
+			s.refreshStops(ctx)
+		case <-alertsTicker.C:
+			// A failure is kept in s.alertsErr for /health
+			s.refreshAlerts(ctx)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, errStopNotFound) {
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *server) handleStop(w http.ResponseWriter, r *http.Request) {
+	stop, err := s.stop(r.PathValue("code"))
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stop)
+}
+
+func (s *server) handleDepartures(w http.ResponseWriter, r *http.Request) {
+	stop, err := s.stop(r.PathValue("code"))
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stop.StopTimes)
+}
+
+// startEventStream prepares w for Server-Sent Events.
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return flusher, true
+}
+
+// writeEvent writes v as a Server-Sent Event with the given name.
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %v\ndata: %s\n\n", name, data)
+	flusher.Flush()
+
+	return err
+}
+
+// handleStopStream streams the stop as a "departures" event right away and
+// again after every refresh.
+func (s *server) handleStopStream(w http.ResponseWriter, r *http.Request) {
+	stop, err := s.stop(r.PathValue("code"))
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	updates := s.subscribeStop(stop.Code)
+	defer s.unsubscribeStop(stop.Code, updates)
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+
+	keepalive := time.NewTicker(serverKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		if err := writeEvent(w, flusher, "departures", stop); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			continue
+		case stop = <-updates:
+		}
+	}
+}
+
+// handleAlertStream streams all current alerts as an "alerts" event and then
+// every new, changed or resolved alert as an "alert" event.
+func (s *server) handleAlertStream(w http.ResponseWriter, r *http.Request) {
+	updates := s.subscribeAlerts()
+	defer s.unsubscribeAlerts(updates)
+
+	alerts, err := s.currentAlerts(r.Context())
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+
+	if err := writeEvent(w, flusher, "alerts", alerts); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(serverKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e := <-updates:
+			if err := writeEvent(w, flusher, "alert", e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := s.currentAlerts(r.Context())
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	// The state is copied under the lock, a slow client mustn't hold it
+	s.mu.RLock()
+	health := struct {
+		Status      string            `json:"status"`
+		Stops       int               `json:"stops"`
+		Refreshed   time.Time         `json:"refreshed"`
+		Alerts      time.Time         `json:"alertsRefreshed"`
+		StopErrors  map[string]string `json:"stopErrors,omitempty"`
+		AlertsError string            `json:"alertsError,omitempty"`
+	}{
+		Status:    "ok",
+		Stops:     len(s.stops),
+		Refreshed: s.refreshed,
+		Alerts:    s.alertsAt,
+	}
+
+	for code, err := range s.stopErrs {
+		if health.StopErrors == nil {
+			health.StopErrors = map[string]string{}
+		}
+		health.StopErrors[code] = err.Error()
+	}
+	if s.alertsErr != nil {
+		health.AlertsError = s.alertsErr.Error()
+	}
+	s.mu.RUnlock()
+
+	status := http.StatusOK
+	if health.StopErrors != nil || health.AlertsError != "" {
+		health.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, health)
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stops/{code}", s.handleStop)
+	mux.HandleFunc("GET /stops/{code}/departures", s.handleDepartures)
+	mux.HandleFunc("GET /stops/{code}/stream", s.handleStopStream)
+	mux.HandleFunc("GET /alerts", s.handleAlerts)
+	mux.HandleFunc("GET /alerts/stream", s.handleAlertStream)
+	mux.HandleFunc("GET /health", s.handleHealth)
+
+	return mux
+}
+
+// serveCommand implements hslterm serve.
+func serveCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	interval := fs.Duration("interval", 20*time.Second, "How often the served stops are refreshed")
+	fs.Parse(args)
+
+	key := resolveApikey(*apikey, *tempApikey)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	s := newServer(key, *interval)
+	go s.poll(ctx)
+
+	httpServer := &http.Server{Addr: *addr, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf(tr("serving on %v")+"\n", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println(redText("server failed: " + err.Error()))
+		os.Exit(1)
+	}
+}
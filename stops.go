@@ -1,59 +1,154 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
 )
 
+// departurePlatform returns the platform the departure leaves from, the
+// platform it was scheduled to leave from and whether the two differ, i.e.
+// the platform changed.
+func departurePlatform(stop Stop, stopTime StopTimes) (string, string, bool) {
+	scheduled := stop.PlatformCode
+	for _, scheduledStop := range stopTime.Trip.Stoptimes {
+		if scheduledStop.StopPosition == stopTime.StopPosition {
+			scheduled = scheduledStop.Stop.PlatformCode
+			break
+		}
+	}
+
+	platform := stopTime.Stop.PlatformCode
+	if platform == "" {
+		return scheduled, scheduled, false
+	}
+
+	return platform, scheduled, scheduled != "" && platform != scheduled
+}
+
+// hasPlatforms reports whether the stop or any of its departures has a
+// platform to show.
+func hasPlatforms(stop Stop) bool {
+	if stop.PlatformCode != "" {
+		return true
+	}
+
+	for _, stopTime := range stop.StopTimes {
+		if stopTime.Stop.PlatformCode != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stopLabel names a stop with its platform, e.g. "Pasila, platform 5", to
+// tell apart the platforms of a station.
+func stopLabel(stop Stop) string {
+	if stop.PlatformCode == "" {
+		return stop.Name
+	}
+
+	return fmt.Sprintf(tr("%v, platform %v"), stop.Name, stop.PlatformCode)
+}
+
+// groupStationPlatforms orders stops so that the platforms of a station are
+// next to each other and in order, e.g. Pasila platforms 1, 2 and 10. Stops
+// without platforms keep their order.
+func groupStationPlatforms(stops []Stop) {
+	first := map[string]int{}
+	for i, stop := range stops {
+		if _, ok := first[stop.Name]; !ok {
+			first[stop.Name] = i
+		}
+	}
+
+	sort.SliceStable(stops, func(i, j int) bool {
+		a, b := stops[i], stops[j]
+		if a.Name != b.Name {
+			return first[a.Name] < first[b.Name]
+		}
+
+		// Numeric platforms sort by their number
+		if len(a.PlatformCode) != len(b.PlatformCode) {
+			return len(a.PlatformCode) < len(b.PlatformCode)
+		}
+		return a.PlatformCode < b.PlatformCode
+	})
+}
+
 func printStop(stop Stop) {
-	fmt.Printf(bold("Stop: %v (%v, %v) %v")+"\n", stop.Name, stop.Desc, stop.Code,
-		transportModeEmoji(stop.VehicleMode))
-	fmt.Printf(bold("Location: %v, %v")+"\n", stop.Lat, stop.Lon)
+	fmt.Printf(bold(tr("Stop: %v (%v, %v) %v %v"))+"\n", stopLabel(stop), stop.Desc, stop.Code,
+		transportModeEmoji(stop.VehicleMode), wheelchairIcon(stop.WheelchairBoarding))
+	fmt.Printf(bold(tr("Location: %v, %v"))+"\n", stop.Lat, stop.Lon)
+	if stop.ZoneID != "" {
+		fmt.Printf(bold(tr("Zone: %v"))+"\n", stop.ZoneID)
+	}
 
-	fmt.Println("Routes: ")
+	fmt.Println(tr("Routes:"))
 	for _, route := range stop.Routes {
-		fmt.Printf("%v\t%v - %v\n", transportModeEmoji(route.Mode), route.ShortName, route.LongName)
+		fmt.Printf("%v\t%v - %v\n", transportModeEmoji(route.Mode), routeLabel(route, route.ShortName), route.LongName)
 	}
 	fmt.Print("\n")
 
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Route", "Departing", "Time left"})
+	platforms := hasPlatforms(stop)
+	header := table.Row{tr("Route"), tr("Departing"), tr("Time left")}
+	if platforms {
+		header = append(header, tr("Platform"))
+	}
+	t.AppendHeader(header)
 
 	t.SetStyle(table.StyleRounded)
 
 	for _, stopTime := range stop.StopTimes {
-		stopTime.RealtimeDeparture += stopTime.ServiceDay
+		departs := departureTime(stopTime)
 
-		routeName := fmt.Sprintf("%v - %v", bold(stopTime.Trip.RouteShortName), stopTime.Headsign)
+		route := routeLabel(stopTime.Trip.Route, stopTime.Trip.RouteShortName)
+		routeName := fmt.Sprintf("%v - %v", route, stopTime.Headsign)
 		if stopTime.RealtimeState == "CANCELED" {
-			routeName = redText(routeName + " (CANCELED)")
+			routeName = redText(fmt.Sprintf("%v - %v (%v)", stopTime.Trip.RouteShortName, stopTime.Headsign, tr("CANCELED")))
 		} else if stopTime.Headsign == "" {
-			routeName = bold(stopTime.Trip.RouteShortName)
+			routeName = route
+		}
+		if icon := wheelchairIcon(stopTime.Trip.WheelchairAccessible); icon != "" {
+			routeName += " " + icon
 		}
 
-		tim := formatTimeLeft(stopTime.RealtimeDeparture)
-		if tim == "Now" {
+		tim := hsltime.FormatRelative(departs, time.Now(), tr)
+		if tim == tr("Now") {
 			tim = bold(tim)
 		}
 
-		t.AppendRow(table.Row{
+		row := table.Row{
 			routeName,
-			time.Unix(stopTime.RealtimeDeparture, 0).Format("15:04"),
+			hsltime.FormatClock(departs),
 			tim,
-		})
+		}
+		if platforms {
+			platform, scheduled, changed := departurePlatform(stop, stopTime)
+			if changed {
+				platform = redText(fmt.Sprintf(tr("%v (was %v)"), platform, scheduled))
+			}
+			row = append(row, platform)
+		}
+		t.AppendRow(row)
 	}
 
 	// print alerts if there are any
 	if len(stop.Alerts) > 0 {
-		fmt.Println(redText("\nAlerts:"))
+		fmt.Println(redText("\n" + tr("Alerts:")))
 		for _, alert := range stop.Alerts {
-			fmt.Printf(redText("\t%v: %v\n"), alert.AlertSeverityLevel, alert.AlertHeaderText)
+			fmt.Printf(redText("\t%v: %v\n"), alert.AlertSeverityLevel, alert.Header(language))
 		}
 		fmt.Print("\n")
 	}
@@ -61,66 +156,173 @@ func printStop(stop Stop) {
 	t.Render()
 }
 
-func newTuiStopFrame(stop Stop, left string, right string) (tview.Primitive, error) {
+// tuiRouteName formats a departure's route and headsign with tview color tags.
+func tuiRouteName(stopTime StopTimes) string {
+	routeName := fmt.Sprintf("%v%v - %v", tuiRouteLabel(stopTime.Trip.Route, stopTime.Trip.RouteShortName), colorTag(theme.Text, ""), tview.Escape(stopTime.Headsign))
+	if stopTime.RealtimeState == "CANCELED" {
+		routeName = fmt.Sprintf("%v%v - %v (%v)[-::-]", colorTag(theme.Warning, "b"), tview.Escape(stopTime.Trip.RouteShortName), tview.Escape(stopTime.Headsign), tr("CANCELED"))
+	} else if stopTime.Headsign == "" {
+		routeName = tuiRouteLabel(stopTime.Trip.Route, stopTime.Trip.RouteShortName)
+	}
+	if icon := wheelchairIcon(stopTime.Trip.WheelchairAccessible); icon != "" {
+		routeName += " " + icon
+	}
+
+	return routeName
+}
+
+// stopBoard is a stop's departure board. It stays on screen for the lifetime
+// of the TUI and its cells are updated in place when the data changes.
+type stopBoard struct {
+	*tview.Frame
+
+	table  *tview.Table
+	stop   Stop
+	left   string
+	right  string
+	status string
+
+	// onSelect, if set, is called with the departure on the row the user
+	// pressed enter on.
+	onSelect func(StopTimes)
+}
+
+func newTuiStopFrame(stop Stop, left string, right string) (*stopBoard, error) {
 	table := tview.NewTable().
 		SetBorders(true).
 		SetFixed(1, 3).
-		SetCell(0, 0, tview.NewTableCell("Route").SetAlign(tview.AlignCenter).SetExpansion(1)).
-		SetCell(0, 1, tview.NewTableCell("Departing").SetAlign(tview.AlignCenter).SetExpansion(1)).
-		SetCell(0, 2, tview.NewTableCell("Time left").SetExpansion(1))
+		SetSelectable(true, false).
+		SetCell(0, 0, tview.NewTableCell(tr("Route")).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false)).
+		SetCell(0, 1, tview.NewTableCell(tr("Departing")).SetAlign(tview.AlignCenter).SetExpansion(1).SetSelectable(false)).
+		SetCell(0, 2, tview.NewTableCell(tr("Time left")).SetExpansion(1).SetSelectable(false))
+
+	board := &stopBoard{
+		Frame: tview.NewFrame(table).SetBorders(1, 1, 2, 2, 4, 4),
+		table: table,
+	}
+	table.SetSelectedFunc(func(row int, _ int) {
+		if board.onSelect != nil && row >= 1 && row <= len(board.stop.StopTimes) {
+			board.onSelect(board.stop.StopTimes[row-1])
+		}
+	})
+	board.update(stop, left, right)
+
+	return board, nil
+}
+
+// update replaces the stop shown on the board. The selected row is kept when
+// the board is refreshed with new data for the same stop.
+func (b *stopBoard) update(stop Stop, left string, right string) {
+	row, _ := b.table.GetSelection()
+	if b.stop.GtfsID != stop.GtfsID {
+		row = 1
+	}
+
+	b.stop = stop
+	b.left = left
+	b.right = right
+
+	for b.table.GetRowCount() > len(stop.StopTimes)+1 {
+		b.table.RemoveRow(b.table.GetRowCount() - 1)
+	}
+
+	platforms := hasPlatforms(stop)
+	if platforms {
+		b.table.SetCell(0, 3, tview.NewTableCell(tr("Platform")).SetAlign(tview.AlignCenter).SetSelectable(false))
+	} else if b.table.GetColumnCount() > 3 {
+		b.table.RemoveColumn(3)
+	}
 
 	for i, stopTime := range stop.StopTimes {
-		stopTime.RealtimeDeparture += stopTime.ServiceDay
+		departs := departureTime(stopTime)
 
-		routeName := fmt.Sprintf("[white]%v - %v", stopTime.Trip.RouteShortName, stopTime.Headsign)
-		if stopTime.RealtimeState == "CANCELED" {
-			routeName = "[bold][red]" + routeName + " (CANCELED)[-]"
-		} else if stopTime.Headsign == "" {
-			routeName = "[bold]" + stopTime.Trip.RouteShortName + "[-]"
+		b.table.SetCellSimple(i+1, 0, tuiRouteName(stopTime))
+		b.table.SetCellSimple(i+1, 1, hsltime.FormatClock(departs))
+		b.table.SetCellSimple(i+1, 2, hsltime.FormatRelative(departs, time.Now(), tr))
+
+		if platforms {
+			platform, scheduled, changed := departurePlatform(stop, stopTime)
+			if changed {
+				platform = colorTag(theme.Warning, "b") + fmt.Sprintf(tr("%v (was %v)"), platform, scheduled) + "[-::-]"
+			}
+			b.table.SetCell(i+1, 3, tview.NewTableCell(platform).SetAlign(tview.AlignCenter))
 		}
+	}
 
-		table.SetCellSimple(i+1, 0, routeName)
-		table.SetCellSimple(i+1, 1, time.Unix(stopTime.RealtimeDeparture, 0).Format("15:04"))
-		table.SetCellSimple(i+1, 2, formatTimeLeft(stopTime.RealtimeDeparture))
+	if row >= b.table.GetRowCount() {
+		row = b.table.GetRowCount() - 1
 	}
+	if row < 1 {
+		row = 1
+	}
+	b.table.Select(row, 0)
 
-	routesText := "Routes:"
-	for _, route := range stop.Routes {
+	b.drawHeader()
+}
+
+// setStatus shows a message, such as a failed refresh, below the board. An
+// empty message hides it.
+func (b *stopBoard) setStatus(status string) {
+	b.status = status
+	b.drawHeader()
+}
+
+// tick recomputes the countdowns and the clock without fetching new data.
+func (b *stopBoard) tick() {
+	for i, stopTime := range b.stop.StopTimes {
+		b.table.GetCell(i+1, 2).SetText(hsltime.FormatRelative(departureTime(stopTime), time.Now(), tr))
+	}
+
+	b.drawHeader()
+}
+
+func (b *stopBoard) drawHeader() {
+	routesText := tr("Routes:")
+	for _, route := range b.stop.Routes {
 		routesText += fmt.Sprintf(" %v %v,",
 			transportModeEmoji(route.Mode),
-			route.ShortName,
+			tuiRouteLabel(route, route.ShortName),
 		)
 	}
-	routesText = routesText[:len(routesText)-1]
+	routesText = strings.TrimSuffix(routesText, ",")
 
-	frame := tview.NewFrame(table).
-		SetBorders(1, 1, 2, 2, 4, 4).
-		AddText("hslterm", true, tview.AlignLeft, tcell.ColorWhite).
-		AddText(time.Now().Format("15:04 02.01.2006"), true, tview.AlignRight, tcell.ColorWhite).
+	b.Clear().
+		AddText("hslterm", true, tview.AlignLeft, themeColor(theme.Text)).
+		AddText(time.Now().In(hsltime.Helsinki).Format("15:04:05 02.01.2006"), true, tview.AlignRight, themeColor(theme.Text)).
 		AddText(
-			fmt.Sprintf("%v %v %v",
-				transportModeEmoji(stop.VehicleMode),
-				stop.Name,
-				transportModeEmoji(stop.VehicleMode)),
-			true, tview.AlignCenter, tcell.ColorWhite).
-		AddText(fmt.Sprintf("%v (%v)", stop.Desc, stop.Code), true, tview.AlignCenter, tcell.ColorRed).
-		AddText(routesText, false, tview.AlignCenter, tcell.ColorBlue)
+			strings.TrimSpace(fmt.Sprintf("%v %v %v %v",
+				transportModeEmoji(b.stop.VehicleMode),
+				stopLabel(b.stop),
+				transportModeEmoji(b.stop.VehicleMode),
+				wheelchairIcon(b.stop.WheelchairBoarding))),
+			true, tview.AlignCenter, themeColor(theme.Text)).
+		AddText(withZone(b.stop.Desc, b.stop.Code, b.stop.ZoneID), true, tview.AlignCenter, themeColor(theme.Accent)).
+		AddText(routesText, false, tview.AlignCenter, themeColor(theme.Info))
+
+	if b.left != "" || b.right != "" {
+		b.AddText(tr("m for menu"), false, tview.AlignCenter, themeColor(theme.Hint))
+	}
+	if b.onSelect != nil {
+		b.AddText(tr("enter to see where the vehicle is"), false, tview.AlignCenter, themeColor(theme.Hint))
+	}
 
-	if left != "" || right != "" {
-		frame.AddText("m for menu", false, tview.AlignCenter, tcell.ColorLightBlue)
+	if b.status != "" {
+		b.AddText(b.status, false, tview.AlignCenter, themeColor(theme.Warning))
 	}
 
-	if left != "" {
-		frame.AddText("← ("+left+")", false, tview.AlignLeft, tcell.ColorBlue)
+	if b.left != "" {
+		b.AddText("← ("+b.left+")", false, tview.AlignLeft, themeColor(theme.Info))
 	}
-	if right != "" {
-		frame.AddText("("+right+") →", false, tview.AlignRight, tcell.ColorBlue)
+	if b.right != "" {
+		b.AddText("("+b.right+") →", false, tview.AlignRight, themeColor(theme.Info))
 	}
-
-	return frame, nil
 }
 
 func stopsGetLeftRight(stops []Stop, i int) (left string, right string) {
+	if len(stops) < 2 {
+		return
+	}
+
 	if i == 0 {
 		left = fmt.Sprintf("%v - %v", stops[len(stops)-1].Code, stops[len(stops)-1].Desc)
 		right = fmt.Sprintf("%v - %v", stops[i+1].Desc, stops[i+1].Code)
@@ -135,8 +337,46 @@ func stopsGetLeftRight(stops []Stop, i int) (left string, right string) {
 	return
 }
 
-func tuiDisplayStops(stops []Stop, apikey string) {
+// tuiDisplayStops shows the stops' departure boards. Enter on a departure
+// tracks its vehicle with the positions from broker.
+func tuiDisplayStops(stops []Stop, apikey string, broker string, hooks *hookRunner) {
+	if len(stops) == 0 {
+		fmt.Println(tr("no stops found"))
+		os.Exit(0)
+	}
+
 	app := tview.NewApplication()
+	backToSearch := false
+
+	i := 0
+	left, right := stopsGetLeftRight(stops, i)
+	board, err := newTuiStopFrame(stops[i], left, right)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	pages := tview.NewPages().AddPage("board", board, true, true)
+	onMenu := false
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// stopTracking is set while the tracker of a departure is open
+	var stopTracking context.CancelFunc
+	board.onSelect = func(stopTime StopTimes) {
+		var trackCtx context.Context
+		trackCtx, stopTracking = context.WithCancel(ctx)
+
+		tracker := newTripTracker(stopTime)
+		pages.AddAndSwitchToPage("tracker", tracker, true)
+		go trackTrip(trackCtx, app, tracker, apikey, broker, stopTime)
+	}
+	closeTracker := func() {
+		stopTracking()
+		stopTracking = nil
+		pages.RemovePage("tracker")
+		pages.SwitchToPage("board")
+	}
+	board.drawHeader()
 
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
@@ -148,113 +388,78 @@ func tuiDisplayStops(stops []Stop, apikey string) {
 			app.Stop()
 
 		case tcell.KeyEsc:
+			if stopTracking != nil {
+				closeTracker()
+				return nil
+			}
+
+			backToSearch = true
 			app.Stop()
-			tuiDisplaySearch(apikey)
 		}
 		return event
 	})
 
-	var frame tview.Primitive
-	var err error
+	showStop := func(stopIndex int) {
+		i = stopIndex
+		left, right := stopsGetLeftRight(stops, i)
+		board.update(stops[i], left, right)
 
-	if len(stops) == 0 {
-		fmt.Println("no stops found")
-		os.Exit(0)
-	} else if len(stops) == 1 {
-		frame, err = newTuiStopFrame(stops[0], "", "")
-		if err != nil {
-			os.Exit(1)
-		}
+		pages.SwitchToPage("board")
+		onMenu = false
+	}
 
-		if err := app.SetRoot(frame, true).Run(); err != nil {
-			panic(err)
+	if len(stops) > 1 {
+		list := tview.NewList()
+		list.AddItem(tr("Cancel"), "", 'c', func() {
+			showStop(i)
+		})
+
+		for stopIndex, stop := range stops {
+			buttonTitle := fmt.Sprintf("%v %v - %v", stopLabel(stop), stop.Code, stop.Desc)
+			if stop.ZoneID != "" {
+				buttonTitle += ", " + formatZone(stop.ZoneID)
+			}
+
+			shortcut := rune(0)
+
+			if stopIndex < 9 {
+				shortcut = rune('1' + stopIndex)
+			} else if stopIndex == 9 {
+				shortcut = '0'
+			}
+
+			list.AddItem(buttonTitle, "", shortcut, func() {
+				showStop(stopIndex)
+			})
 		}
-	} else {
-		frame, err = newTuiStopFrame(
-			stops[0],
-			fmt.Sprintf("%v - %v", stops[len(stops)-1].Code, stops[len(stops)-1].Desc),
-			fmt.Sprintf("%v - %v", stops[1].Desc, stops[1].Code),
-		)
-		layout := tview.NewFlex().
-			SetDirection(tview.FlexRow).
-			AddItem(frame, 0, 1, true)
 
-		onMenu := false
-		i := 0
+		list.AddItem(tr("Quit"), "", 0, func() {
+			app.Stop()
+		})
+
+		menu := tview.NewFrame(list).
+			SetBorders(1, 1, 1, 1, 2, 2).
+			AddText(tr("Select the stop to view"), true, tview.AlignCenter, themeColor(theme.Text)).
+			AddText("hslterm", false, tview.AlignCenter, themeColor(theme.Hint))
+		pages.AddPage("menu", menu, true, false)
 
-		layout.SetInputCapture(
+		pages.SetInputCapture(
 			func(event *tcell.EventKey) *tcell.EventKey {
+				if stopTracking != nil {
+					return event
+				}
+
 				switch event.Key() {
 				case tcell.KeyRune:
 					if event.Rune() == 'm' {
 						if onMenu {
-							layout.Clear()
-							left, right := stopsGetLeftRight(stops, i)
-							newframe, err := newTuiStopFrame(stops[i], left, right)
-							if err != nil {
-								panic(err)
-							}
-
-							layout.Clear()
-							layout.AddItem(newframe, 0, 1, true)
-							app.SetRoot(layout, true)
-
-							onMenu = false
+							showStop(i)
 						} else {
-							list := tview.NewList()
-							list.AddItem("Cancel", "", 'c', func() {
-								left, right := stopsGetLeftRight(stops, i)
-								newframe, err := newTuiStopFrame(stops[i], left, right)
-								if err != nil {
-									panic(err)
-								}
-
-								layout.Clear()
-								layout.AddItem(newframe, 0, 1, true)
-								app.SetRoot(layout, true)
-
-								onMenu = false
-							})
-
-							for stopIndex, stop := range stops {
-								buttonTitle := fmt.Sprintf("%v %v - %v", stop.Name, stop.Code, stop.Desc)
-
-								shortcut := rune(0)
-
-								if stopIndex < 9 {
-									shortcut = rune('1' + stopIndex)
-								} else if stopIndex == 9 {
-									shortcut = '0'
-								}
-
-								list.AddItem(buttonTitle, "", shortcut, func() {
-									left, right := stopsGetLeftRight(stops, stopIndex)
-									newframe, err := newTuiStopFrame(stop, left, right)
-									if err != nil {
-										panic(err)
-									}
-
-									layout.Clear()
-									layout.AddItem(newframe, 0, 1, true)
-									app.SetRoot(layout, true)
-
-									onMenu = false
-								})
-							}
-
-							list.AddItem("Quit", "", 0, func() {
-								app.Stop()
-							})
-
-							layout.Clear()
-							frame := tview.NewFrame(list).
-								SetBorders(1, 1, 1, 1, 2, 2).
-								AddText("Select the stop to view", true, tview.AlignCenter, tcell.ColorWhite).
-								AddText("hslterm", false, tview.AlignCenter, tcell.ColorLightBlue)
-							layout.Clear().AddItem(frame, 0, 1, true)
-							app.SetRoot(layout, true)
+							pages.SwitchToPage("menu")
 							onMenu = true
 						}
+
+						return nil
 					}
 
 				case tcell.KeyLeft:
@@ -263,86 +468,79 @@ func tuiDisplayStops(stops []Stop, apikey string) {
 					}
 
 					if i == 0 {
-						i = len(stops) - 1
+						showStop(len(stops) - 1)
 					} else {
-						i--
+						showStop(i - 1)
 					}
 
-					left, right := stopsGetLeftRight(stops, i)
-
-					newframe, err := newTuiStopFrame(stops[i], left, right)
-
-					if err != nil {
-						panic(err)
-					}
-
-					layout.Clear()
-					layout.AddItem(newframe, 0, 1, true)
-					app.SetRoot(layout, true)
+					return nil
 				case tcell.KeyRight:
 					if onMenu {
 						break
 					}
 
 					if i == len(stops)-1 {
-						i = 0
+						showStop(0)
 					} else {
-						i++
-					}
-
-					left, right := stopsGetLeftRight(stops, i)
-
-					newframe, err := newTuiStopFrame(stops[i], left, right)
-
-					if err != nil {
-						panic(err)
+						showStop(i + 1)
 					}
 
-					layout.Clear()
-					layout.AddItem(newframe, 0, 1, true)
-					app.SetRoot(layout, true)
+					return nil
 				}
 
 				return event
 			},
 		)
+	}
 
-		if err != nil {
-			os.Exit(1)
-		}
-
-		go func() {
-			ticker := time.NewTicker(20 * time.Second)
-			for {
-				select {
-				case <-ticker.C:
-					if onMenu {
-						continue
-					}
+	// All of stops, i, onMenu and stopTracking are only touched on the UI goroutine, the
+	// worker hands over new data as snapshots.
+	go func() {
+		clock := time.NewTicker(time.Second)
+		defer clock.Stop()
+
+		snapshots := watchStops(ctx, apikey, stops, departuresRefreshInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.C:
+				app.QueueUpdateDraw(board.tick)
+			case snapshot, ok := <-snapshots:
+				if !ok {
+					return
+				}
 
-					app.QueueUpdateDraw(func() {
-						stops, err := updateStopData(stops, apikey)
-						if err != nil {
-							panic(err)
-						}
+				if snapshot.Err == nil {
+					hooks.emit(stopEvents(snapshot.Stops, snapshot.Fetched)...)
+				}
 
-						left, right := stopsGetLeftRight(stops, i)
-						newframe, err := newTuiStopFrame(stops[i], left, right)
-						if err != nil {
-							panic(err)
-						}
+				app.QueueUpdateDraw(func() {
+					if snapshot.Err != nil {
+						board.setStatus(tr("refresh failed: ") + snapshot.Err.Error())
+						return
+					}
 
-						layout.Clear()
-						layout.AddItem(newframe, 0, 1, true)
-						app.SetRoot(layout, true)
+					stops = snapshot.Stops
+					if onMenu {
+						return
+					}
 
-					})
-				}
+					left, right := stopsGetLeftRight(stops, i)
+					board.status = ""
+					board.update(stops[i], left, right)
+				})
 			}
-		}()
-
-		if err := app.SetRoot(layout, true).Run(); err != nil {
-			panic(err)
 		}
+	}()
+
+	err = app.SetRoot(pages, true).Run()
+	cancel()
+	if err != nil {
+		panic(err)
+	}
+
+	if backToSearch {
+		tuiDisplaySearch(apikey, broker, hooks)
 	}
 }
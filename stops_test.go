@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fakeStationStop is a Pasila platform whose second departure was moved from
+// platform 3 to platform 4 after the timetable was published.
+const fakeStationStop = `{"gtfsId": "HSL:1174503", "code": "H0555", "name": "Pasila", "vehicleMode": "RAIL", "platformCode": "3",
+	"stoptimesWithoutPatterns": [
+		{"headsign": "Kerava", "scheduledDeparture": 36000, "serviceDay": 1735682400, "stopPosition": 4, "stop": {"platformCode": "3"},
+		"trip": {"routeShortName": "K", "stoptimes": [{"stopPosition": 3, "stop": {"platformCode": "9"}}, {"stopPosition": 4, "stop": {"platformCode": "3"}}]}},
+		{"headsign": "Lentoasema", "scheduledDeparture": 36300, "serviceDay": 1735682400, "stopPosition": 4, "stop": {"platformCode": "4"},
+		"trip": {"routeShortName": "I", "stoptimes": [{"stopPosition": 3, "stop": {"platformCode": "9"}}, {"stopPosition": 4, "stop": {"platformCode": "3"}}]}},
+		{"headsign": "Tikkurila", "scheduledDeparture": 36600, "serviceDay": 1735682400, "stop": {"platformCode": ""}, "trip": {"routeShortName": "R"}}
+	]}`
+
+func TestDeparturePlatform(t *testing.T) {
+	var stop Stop
+	if err := json.Unmarshal([]byte(fakeStationStop), &stop); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		route         string
+		wantPlatform  string
+		wantScheduled string
+		wantChanged   bool
+	}{
+		{route: "K", wantPlatform: "3", wantScheduled: "3"},
+		{route: "I", wantPlatform: "4", wantScheduled: "3", wantChanged: true},
+		{route: "R", wantPlatform: "3", wantScheduled: "3"},
+	}
+	for i, tt := range tests {
+		stopTime := stop.StopTimes[i]
+		if stopTime.Trip.RouteShortName != tt.route {
+			t.Fatalf("departure %v: got route %v, want %v", i, stopTime.Trip.RouteShortName, tt.route)
+		}
+
+		platform, scheduled, changed := departurePlatform(stop, stopTime)
+		if platform != tt.wantPlatform || scheduled != tt.wantScheduled || changed != tt.wantChanged {
+			t.Errorf("%v: got (%v, %v, %v), want (%v, %v, %v)", tt.route, platform, scheduled, changed, tt.wantPlatform, tt.wantScheduled, tt.wantChanged)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/term"
+)
+
+// Theme is the colours hslterm uses. Colours are names, e.g. "red" or
+// "lightblue", or hex codes, e.g. "#007ac9". An empty colour is the
+// terminal's own text colour.
+type Theme struct {
+	// Text is the colour of normal text such as departures and headers.
+	Text string `json:"text"`
+	// Title is the colour of titles and highlighted things, such as the
+	// kiosk's departure times and the tracked vehicle's next stop.
+	Title string `json:"title"`
+	// Accent is the colour of secondary titles, such as a stop's description.
+	Accent string `json:"accent"`
+	// Info is the colour of details, such as a stop's routes.
+	Info string `json:"info"`
+	// Hint is the colour of key hints.
+	Hint string `json:"hint"`
+	// Warning is the colour of cancellations, errors and empty stations.
+	Warning string `json:"warning"`
+	// Muted is the colour of things that are past, such as passed stops.
+	Muted string `json:"muted"`
+	// Modes are the colours of route names by transport mode, e.g.
+	// "TRAM": "#00985f", for routes HSL hasn't given a colour. Routes of
+	// other modes use Text.
+	Modes map[string]string `json:"modes"`
+	// PlainRoutes turns off HSL's own route colours.
+	PlainRoutes bool `json:"plainRoutes"`
+}
+
+// builtinThemes are the themes that can be used without configuring them.
+var builtinThemes = map[string]Theme{
+	"default": {
+		Text:    "white",
+		Title:   "yellow",
+		Accent:  "red",
+		Info:    "blue",
+		Hint:    "lightblue",
+		Warning: "red",
+		Muted:   "gray",
+	},
+	"high-contrast": {
+		Text:    "white",
+		Title:   "yellow",
+		Accent:  "aqua",
+		Info:    "aqua",
+		Hint:    "white",
+		Warning: "#ff5f5f",
+		Muted:   "silver",
+	},
+	// monochrome uses the terminal's colours, emphasis is left to bold text
+	"monochrome": {PlainRoutes: true},
+	// hsl uses HSL's brand colours of each mode
+	"hsl": {
+		Text:    "white",
+		Title:   "#007ac9",
+		Accent:  "#64be14",
+		Info:    "#00b9e4",
+		Hint:    "#00b9e4",
+		Warning: "#dc0451",
+		Muted:   "gray",
+		Modes: map[string]string{
+			"BUS":    "#007ac9",
+			"TRAM":   "#00985f",
+			"SUBWAY": "#ff6319",
+			"RAIL":   "#8c4799",
+			"FERRY":  "#00b9e4",
+		},
+	},
+}
+
+// theme is the theme in use.
+var theme = builtinThemes["default"]
+
+// userThemes are the themes defined in the config file.
+var userThemes = map[string]Theme{}
+
+// useANSI is false when stdout isn't a terminal, e.g. when piped to a file,
+// and then no colours or hyperlinks are printed.
+var useANSI = term.IsTerminal(int(os.Stdout.Fd()))
+
+// loadThemes picks the theme from the config file, or the monochrome theme
+// if $NO_COLOR is set and the config doesn't name one. -theme overrides both.
+// A broken config file is ignored here, the commands report it.
+func loadThemes() {
+	config, _ := loadConfig()
+	for name, t := range config.Themes {
+		userThemes[name] = t
+	}
+
+	name := config.Theme
+	if name == "" && os.Getenv("NO_COLOR") != "" {
+		name = "monochrome"
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	if err := setTheme(name); err != nil {
+		fmt.Println(redText(err.Error()))
+	}
+}
+
+// setTheme sets the theme by name. User themes start from the default theme,
+// so they only need to list the colours they change.
+func setTheme(name string) error {
+	if t, ok := userThemes[name]; ok {
+		theme = mergeTheme(builtinThemes["default"], t)
+	} else if t, ok := builtinThemes[name]; ok {
+		theme = t
+	} else {
+		names := []string{}
+		for name := range builtinThemes {
+			names = append(names, name)
+		}
+		for name := range userThemes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		return fmt.Errorf("unknown theme %q, use one of %v", name, strings.Join(names, ", "))
+	}
+
+	tview.Styles.PrimaryTextColor = themeColor(theme.Text)
+	tview.Styles.BorderColor = themeColor(theme.Text)
+	tview.Styles.TitleColor = themeColor(theme.Text)
+
+	return nil
+}
+
+// mergeTheme returns base with the colours set in t replaced.
+func mergeTheme(base Theme, t Theme) Theme {
+	set := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+
+	set(&base.Text, t.Text)
+	set(&base.Title, t.Title)
+	set(&base.Accent, t.Accent)
+	set(&base.Info, t.Info)
+	set(&base.Hint, t.Hint)
+	set(&base.Warning, t.Warning)
+	set(&base.Muted, t.Muted)
+	if t.Modes != nil {
+		base.Modes = t.Modes
+	}
+	base.PlainRoutes = base.PlainRoutes || t.PlainRoutes
+
+	return base
+}
+
+// modeColor returns the colour of route names of the given mode, e.g. "TRAM".
+func (t Theme) modeColor(mode string) string {
+	if c, ok := t.Modes[mode]; ok {
+		return c
+	}
+
+	return t.Text
+}
+
+// routeColors returns the colours of a route's short name. Routes HSL has
+// given a colour are shown like on HSL's signs, in their text colour on their
+// own colour. bg is empty for the others, which get the colour of their mode.
+func (t Theme) routeColors(route Route) (fg string, bg string) {
+	if route.Color == "" || t.PlainRoutes {
+		return t.modeColor(route.Mode), ""
+	}
+
+	fg = "white"
+	if route.TextColor != "" {
+		fg = "#" + route.TextColor
+	}
+
+	return fg, "#" + route.Color
+}
+
+// tuiRouteLabel formats a route's short name in its colours for tview.
+func tuiRouteLabel(route Route, shortName string) string {
+	fg, bg := theme.routeColors(route)
+	if bg == "" {
+		return colorTag(fg, "b") + tview.Escape(shortName) + "[-::-]"
+	}
+
+	return fmt.Sprintf("[%v:%v:b] %v [-:-:-]", fg, bg, tview.Escape(shortName))
+}
+
+// routeLabel formats a route's short name in its colours for the terminal.
+func routeLabel(route Route, shortName string) string {
+	fg, bg := theme.routeColors(route)
+	if bg == "" {
+		return ansiText(fg, true, shortName)
+	}
+
+	return ansiStyle(fg, bg, true, " "+shortName+" ")
+}
+
+// themeColor converts a theme colour for tview.
+func themeColor(c string) tcell.Color {
+	if c == "" {
+		return tcell.ColorDefault
+	}
+
+	return tcell.GetColor(c)
+}
+
+// colorTag returns a tview style tag setting the text colour to c and the
+// given attributes, e.g. "b" for bold.
+func colorTag(c string, attrs string) string {
+	if c == "" {
+		c = "-"
+	}
+	if attrs == "" {
+		return "[" + c + "]"
+	}
+
+	return "[" + c + "::" + attrs + "]"
+}
+
+// colorSupport is how many colours the terminal can show.
+type colorSupport int
+
+const (
+	colors16 colorSupport = iota
+	colors256
+	colorsTrue
+)
+
+// terminalColors is what the terminal running hslterm supports, going by the
+// variables terminals set. The TUI doesn't need this, tcell does the same.
+var terminalColors = detectColors()
+
+func detectColors() colorSupport {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return colorsTrue
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return colors256
+	}
+
+	return colors16
+}
+
+// nearestPaletteColor returns the index of the colour in the first n colours
+// of the xterm palette that is closest to r, g, b.
+func nearestPaletteColor(r, g, b int32, n int) int {
+	nearest, best := 0, int32(-1)
+	for i := 0; i < n; i++ {
+		pr, pg, pb := tcell.PaletteColor(i).RGB()
+		d := (r-pr)*(r-pr) + (g-pg)*(g-pg) + (b-pb)*(b-pb)
+		if best < 0 || d < best {
+			nearest, best = i, d
+		}
+	}
+
+	return nearest
+}
+
+// ansiColor returns the SGR parameters that set the text colour, or the
+// background colour if background is set, to c. Empty is the terminal's own
+// colour. Colours the terminal can't show are replaced by the nearest one it
+// can.
+func ansiColor(c string, background bool) string {
+	color := themeColor(c)
+	if !color.Valid() {
+		return ""
+	}
+
+	palette := 16
+	if terminalColors != colors16 {
+		palette = 256
+	}
+
+	i := -1
+	if !color.IsRGB() {
+		i = int(color - tcell.ColorValid)
+	}
+	if i < 0 || i >= palette {
+		r, g, b := color.RGB()
+		if terminalColors == colorsTrue {
+			if background {
+				return fmt.Sprintf("48;2;%v;%v;%v", r, g, b)
+			}
+			return fmt.Sprintf("38;2;%v;%v;%v", r, g, b)
+		}
+		i = nearestPaletteColor(r, g, b, palette)
+	}
+
+	offset := 0
+	if background {
+		offset = 10
+	}
+
+	switch {
+	case i < 8:
+		return fmt.Sprint(30 + offset + i)
+	case i < 16:
+		return fmt.Sprint(90 + offset + i - 8)
+	}
+
+	return fmt.Sprintf("%v;5;%v", 38+offset, i)
+}
+
+// ansiText styles s for printing to the terminal in colour c, in bold if
+// bold is set. Nothing is added when stdout isn't a terminal.
+func ansiText(c string, bold bool, s string) string {
+	return ansiStyle(c, "", bold, s)
+}
+
+// ansiStyle is ansiText with a background colour.
+func ansiStyle(fg string, bg string, bold bool, s string) string {
+	if !useANSI {
+		return s
+	}
+
+	params := []string{}
+	if bold {
+		params = append(params, "1")
+	}
+	if color := ansiColor(fg, false); color != "" {
+		params = append(params, color)
+	}
+	if color := ansiColor(bg, true); color != "" {
+		params = append(params, color)
+	}
+	if len(params) == 0 {
+		return s
+	}
+
+	return "\033[" + strings.Join(params, ";") + "m" + s + "\033[0m"
+}
+
+func bold(s string) string {
+	return ansiText(theme.Text, true, s)
+}
+
+func redText(s string) string {
+	return ansiText(theme.Warning, true, s)
+}
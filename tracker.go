@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+const tripRefreshInterval = 20 * time.Second
+
+// gtfsLocalID strips the feed prefix from a GTFS id, e.g. "HSL:1201129" gives
+// "1201129".
+func gtfsLocalID(id string) string {
+	_, local, found := strings.Cut(id, ":")
+	if !found {
+		return id
+	}
+
+	return local
+}
+
+// tripMatchesVehicle reports whether v is the vehicle running trip. HFP
+// identifies a journey by its route, direction, operating day and start time.
+func tripMatchesVehicle(trip Trip, v Vehicle) bool {
+	if len(trip.StopTimes) == 0 || !strings.EqualFold(v.Route, trip.RouteShortName) {
+		return false
+	}
+
+	first := trip.StopTimes[0]
+	if v.StartTime != hsltime.FormatClock(hsltime.ServiceTime(first.ServiceDay, first.ScheduledDeparture)) {
+		return false
+	}
+	if v.OperatingDay != hsltime.ServiceDate(first.ServiceDay).Format("2006-01-02") {
+		return false
+	}
+
+	// GTFS directions are 0 and 1, HFP ones 1 and 2
+	if dir, err := strconv.Atoi(trip.DirectionID); err == nil && v.Direction != strconv.Itoa(dir+1) {
+		return false
+	}
+
+	return true
+}
+
+// tripVehicleFilter returns the filter subscribing to trip's journey only, as
+// far as the trip's data tells which one it is.
+func tripVehicleFilter(trip Trip) vehicleFilter {
+	filter := vehicleFilter{}
+	if mode, ok := hfpModes[trip.Route.Mode]; ok {
+		filter.Modes = []string{mode}
+	}
+	if trip.Route.GtfsID != "" {
+		filter.RouteIDs = []string{hfpRouteID(trip.Route.GtfsID)}
+	}
+	// GTFS directions are 0 and 1, HFP ones 1 and 2
+	if dir, err := strconv.Atoi(trip.DirectionID); err == nil {
+		filter.Direction = strconv.Itoa(dir + 1)
+	}
+	if len(trip.StopTimes) > 0 {
+		first := trip.StopTimes[0]
+		filter.StartTime = hsltime.FormatClock(hsltime.ServiceTime(first.ServiceDay, first.ScheduledDeparture))
+	}
+
+	return filter
+}
+
+// tripTracker shows where a departure's vehicle is: the trip's stops with the
+// delay at each and a marker at the stop the vehicle is heading to.
+type tripTracker struct {
+	*tview.Frame
+
+	table         *tview.Table
+	stopTime      StopTimes
+	trip          Trip
+	vehicle       *Vehicle
+	status        string
+	vehicleStatus string
+	followed      bool
+}
+
+func newTripTracker(stopTime StopTimes) *tripTracker {
+	table := tview.NewTable().
+		SetFixed(1, 0).
+		SetSelectable(true, false)
+	for col, title := range []string{"", tr("Stop"), tr("Scheduled"), tr("Expected"), tr("Delay")} {
+		table.SetCell(0, col, tview.NewTableCell(title).SetExpansion(min(col, 1)).SetSelectable(false).SetAttributes(tcell.AttrBold))
+	}
+
+	tracker := &tripTracker{
+		Frame:    tview.NewFrame(table).SetBorders(1, 1, 2, 2, 4, 4),
+		table:    table,
+		stopTime: stopTime,
+	}
+	tracker.draw()
+
+	return tracker
+}
+
+// nextStop returns the index of the stop the vehicle is heading to. Without a
+// vehicle position it's the first stop the trip hasn't departed from.
+func (t *tripTracker) nextStop(now time.Time) int {
+	if t.vehicle != nil {
+		if t.vehicle.NextStop == "" {
+			return len(t.trip.StopTimes)
+		}
+		for i, stopTime := range t.trip.StopTimes {
+			if gtfsLocalID(stopTime.Stop.GtfsID) == t.vehicle.NextStop {
+				return i
+			}
+		}
+	}
+
+	for i, stopTime := range t.trip.StopTimes {
+		if hsltime.ServiceTime(stopTime.ServiceDay, stopTime.RealtimeDeparture).After(now) {
+			return i
+		}
+	}
+
+	return len(t.trip.StopTimes)
+}
+
+func (t *tripTracker) update(trip Trip) {
+	t.trip = trip
+	t.status = ""
+	t.draw()
+}
+
+func (t *tripTracker) setVehicle(v Vehicle) {
+	t.vehicle = &v
+	t.vehicleStatus = ""
+	t.draw()
+}
+
+func (t *tripTracker) setStatus(status string) {
+	t.status = status
+	t.draw()
+}
+
+func (t *tripTracker) setVehicleStatus(status string) {
+	t.vehicleStatus = status
+	t.draw()
+}
+
+func (t *tripTracker) draw() {
+	now := time.Now()
+	next := t.nextStop(now)
+
+	for t.table.GetRowCount() > len(t.trip.StopTimes)+1 {
+		t.table.RemoveRow(t.table.GetRowCount() - 1)
+	}
+
+	for i, stopTime := range t.trip.StopTimes {
+		marker, color := "", themeColor(theme.Text)
+		switch {
+		case i < next:
+			marker, color = "·", themeColor(theme.Muted)
+		case i == next:
+			marker, color = "▶", themeColor(theme.Title)
+		}
+
+		name := withZone(stopTime.Stop.Name, stopTime.Stop.Code, stopTime.Stop.ZoneID)
+		if gtfsLocalID(stopTime.Stop.GtfsID) == gtfsLocalID(t.currentStopID()) {
+			name = "[::b]" + tview.Escape(name) + "[::-]"
+		} else {
+			name = tview.Escape(name)
+		}
+
+		delay := formatDelay(float64(stopTime.RealtimeDeparture - stopTime.ScheduledDeparture))
+		if stopTime.RealtimeState == "CANCELED" {
+			delay = colorTag(theme.Warning, "") + tr("CANCELED") + "[-]"
+		}
+
+		cells := []string{
+			marker,
+			name,
+			hsltime.FormatClock(hsltime.ServiceTime(stopTime.ServiceDay, stopTime.ScheduledDeparture)),
+			hsltime.FormatClock(hsltime.ServiceTime(stopTime.ServiceDay, stopTime.RealtimeDeparture)),
+			delay,
+		}
+		for col, text := range cells {
+			t.table.SetCell(i+1, col, tview.NewTableCell(text).SetExpansion(min(col, 1)).SetTextColor(color))
+		}
+	}
+
+	// Start with the vehicle in view, after that the user scrolls
+	if !t.followed && len(t.trip.StopTimes) > 0 {
+		t.table.Select(min(next, len(t.trip.StopTimes)-1)+1, 0)
+		t.followed = true
+	}
+
+	t.drawHeader(now)
+}
+
+// currentStopID is the GTFS id of the stop the board the tracker was opened
+// from is for.
+func (t *tripTracker) currentStopID() string {
+	for _, stopTime := range t.trip.StopTimes {
+		if stopTime.ScheduledDeparture == t.stopTime.ScheduledDeparture {
+			return stopTime.Stop.GtfsID
+		}
+	}
+
+	return ""
+}
+
+func (t *tripTracker) drawHeader(now time.Time) {
+	headsign := t.trip.TripHeadsign
+	if headsign == "" {
+		headsign = t.stopTime.Headsign
+	}
+
+	t.Clear().
+		AddText("hslterm", true, tview.AlignLeft, themeColor(theme.Text)).
+		AddText(now.In(hsltime.Helsinki).Format("15:04:05 02.01.2006"), true, tview.AlignRight, themeColor(theme.Text)).
+		AddText(fmt.Sprintf("%v %v → %v", transportModeEmoji(t.trip.Route.Mode), t.stopTime.Trip.RouteShortName, headsign),
+			true, tview.AlignCenter, themeColor(theme.Text))
+
+	if t.vehicle != nil {
+		v := t.vehicle
+		late := tr("on time")
+		if v.Delay >= 60 {
+			late = fmt.Sprintf(tr("%v min late"), v.Delay/60)
+		} else if v.Delay <= -60 {
+			late = fmt.Sprintf(tr("%v min early"), -v.Delay/60)
+		}
+
+		doors := ""
+		if v.DoorsOpen {
+			doors = tr(", doors open")
+		}
+
+		t.AddText(fmt.Sprintf(tr("Vehicle %v, %.0f km/h, %v%v, seen %vs ago"),
+			v.ID, v.Speed*3.6, late, doors, int(now.Sub(v.Time).Seconds())),
+			true, tview.AlignCenter, themeColor(theme.Info))
+	} else if len(t.trip.StopTimes) > 0 {
+		t.AddText(tr("Waiting for the vehicle's position, showing the realtime timetable"), true, tview.AlignCenter, themeColor(theme.Info))
+	} else {
+		t.AddText(tr("Loading trip..."), true, tview.AlignCenter, themeColor(theme.Info))
+	}
+
+	t.AddText(tr("esc to go back"), false, tview.AlignCenter, themeColor(theme.Hint))
+
+	if t.status != "" {
+		t.AddText(t.status, false, tview.AlignCenter, themeColor(theme.Warning))
+	}
+	if t.vehicleStatus != "" {
+		t.AddText(t.vehicleStatus, false, tview.AlignCenter, themeColor(theme.Warning))
+	}
+}
+
+// trackTrip keeps tracker up to date until ctx is cancelled. The trip's times
+// are refreshed from the API and the vehicle's position comes from the HFP
+// feed on broker.
+func trackTrip(ctx context.Context, app *tview.Application, tracker *tripTracker, apikey string, broker string, stopTime StopTimes) {
+	if stopTime.Trip.GtfsID == "" {
+		app.QueueUpdateDraw(func() {
+			tracker.setStatus(tr("no trip information for this departure"))
+		})
+		return
+	}
+
+	var trip Trip
+	var vehicles <-chan Vehicle
+	connected := make(chan (<-chan Vehicle), 1)
+	connecting := false
+
+	connect := func(trip Trip) {
+		connecting = true
+
+		filter := tripVehicleFilter(trip)
+
+		go func() {
+			ch, err := watchVehicles(ctx, broker, filter)
+			if err != nil {
+				if ctx.Err() == nil {
+					app.QueueUpdateDraw(func() {
+						tracker.setVehicleStatus(tr("no vehicle positions: ") + err.Error())
+					})
+				}
+				return
+			}
+			connected <- ch
+		}()
+	}
+
+	refresh := func() {
+		updated, err := getTrip(ctx, apikey, stopTime.Trip.GtfsID, stopTime.ServiceDay)
+		if ctx.Err() != nil {
+			return
+		}
+
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				tracker.setStatus(tr("refresh failed: ") + err.Error())
+				return
+			}
+			tracker.update(updated)
+		})
+
+		if err == nil {
+			trip = updated
+			if !connecting {
+				connect(trip)
+			}
+		}
+	}
+	refresh()
+
+	refreshTicker := time.NewTicker(tripRefreshInterval)
+	defer refreshTicker.Stop()
+	clock := time.NewTicker(time.Second)
+	defer clock.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshTicker.C:
+			refresh()
+		case <-clock.C:
+			app.QueueUpdateDraw(tracker.draw)
+		case vehicles = <-connected:
+		case v, ok := <-vehicles:
+			if !ok {
+				vehicles = nil
+				continue
+			}
+			if !tripMatchesVehicle(trip, v) {
+				continue
+			}
+
+			app.QueueUpdateDraw(func() {
+				tracker.setVehicle(v)
+			})
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/rivo/tview"
 )
 
-func tuiDisplaySearch(apikey string) {
+func tuiDisplaySearch(apikey string, broker string, hooks *hookRunner) {
 	app := tview.NewApplication()
 
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
@@ -25,7 +25,7 @@ func tuiDisplaySearch(apikey string) {
 		AddItem(nil, 0, 1, false).
 		AddItem(tview.NewForm().
 			AddFormItem(inputField).
-			AddButton("Search for stops", func() {
+			AddButton(tr("Search for stops"), func() {
 				stops, err := getStopData(apikey, inputField.GetText(), 5)
 				if err != nil {
 					app.Stop()
@@ -33,14 +33,14 @@ func tuiDisplaySearch(apikey string) {
 				}
 
 				app.Stop()
-				tuiDisplayStops(stops, apikey)
+				tuiDisplayStops(stops, apikey, broker, hooks)
 			}), 0, 1, true).
 		AddItem(nil, 0, 1, false)
 
 	frame := tview.NewFrame(flex).
 		SetBorders(1, 1, 1, 1, 1, 1).
-		AddText("Search for HSL stops", true, tview.AlignCenter, tcell.ColorWhite).
-		AddText("hslterm", false, tview.AlignCenter, tcell.ColorLightBlue)
+		AddText(tr("Search for HSL stops"), true, tview.AlignCenter, themeColor(theme.Text)).
+		AddText("hslterm", false, tview.AlignCenter, themeColor(theme.Hint))
 
 	layout := tview.NewFlex().AddItem(frame, 0, 1, true)
 
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/theshouttingparrot/hslterm/internal/hsltime"
+)
+
+// defaultMQTTBroker is HSL's public high-frequency positioning broker.
+const defaultMQTTBroker = "mqtts://mqtt.hsl.fi:8883"
+
+// hfpTopicPrefix is the topic vehicle positions of ongoing journeys are
+// published under. The rest of the topic is
+// <mode>/<operator>/<vehicle>/<route id>/<direction>/<headsign>/<start time>/<next stop>/<geohash level>/<geohash>/<sid>.
+const hfpTopicPrefix = "/hfp/v2/journey/ongoing/vp/"
+
+// Vehicle is one position report of a vehicle from HSL's high-frequency
+// positioning (HFP) feed.
+type Vehicle struct {
+	// ID is the operator and vehicle number, e.g. "22/1234".
+	ID string `json:"id"`
+	// Mode is the transport mode from the topic, e.g. "bus" or "metro".
+	Mode string `json:"mode"`
+	// Route is the route's short name, e.g. "550".
+	Route string `json:"route"`
+	// RouteID is the route's GTFS id without the feed prefix, e.g. "2550".
+	RouteID   string `json:"routeId"`
+	Direction string `json:"direction"`
+	Headsign  string `json:"headsign"`
+	// StartTime is the scheduled start of the journey, e.g. "14:05".
+	StartTime    string `json:"startTime"`
+	OperatingDay string `json:"operatingDay"`
+	// NextStop is the GTFS id of the next stop without the feed prefix, or
+	// empty at the end of the journey.
+	NextStop string  `json:"nextStop"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	// Speed is in metres per second.
+	Speed float64 `json:"speed"`
+	// Heading is in degrees clockwise from north.
+	Heading int `json:"heading"`
+	// Delay is how many seconds the vehicle is behind its schedule, negative
+	// if it's ahead.
+	Delay     int       `json:"delay"`
+	DoorsOpen bool      `json:"doorsOpen"`
+	Time      time.Time `json:"time"`
+}
+
+// hfpPayload is the JSON payload of a vp message.
+type hfpPayload struct {
+	VP struct {
+		Desi  string  `json:"desi"`
+		Dir   string  `json:"dir"`
+		Oper  int     `json:"oper"`
+		Veh   int     `json:"veh"`
+		Tst   string  `json:"tst"`
+		Spd   float64 `json:"spd"`
+		Hdg   int     `json:"hdg"`
+		Lat   float64 `json:"lat"`
+		Long  float64 `json:"long"`
+		Dl    int     `json:"dl"`
+		Drst  int     `json:"drst"`
+		Oday  string  `json:"oday"`
+		Start string  `json:"start"`
+		Route string  `json:"route"`
+	} `json:"VP"`
+}
+
+// parseHFP decodes a vehicle position message.
+func parseHFP(topic string, payload []byte) (Vehicle, error) {
+	if !strings.HasPrefix(topic, hfpTopicPrefix) {
+		return Vehicle{}, fmt.Errorf("not a vehicle position topic: %v", topic)
+	}
+	fields := strings.Split(strings.TrimPrefix(topic, hfpTopicPrefix), "/")
+	if len(fields) < 8 {
+		return Vehicle{}, fmt.Errorf("malformed vehicle position topic: %v", topic)
+	}
+
+	var data hfpPayload
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return Vehicle{}, err
+	}
+	vp := data.VP
+
+	v := Vehicle{
+		ID:           fmt.Sprintf("%v/%v", vp.Oper, vp.Veh),
+		Mode:         fields[0],
+		Route:        vp.Desi,
+		RouteID:      vp.Route,
+		Direction:    vp.Dir,
+		Headsign:     fields[5],
+		StartTime:    vp.Start,
+		OperatingDay: vp.Oday,
+		Lat:          vp.Lat,
+		Lon:          vp.Long,
+		Speed:        vp.Spd,
+		Heading:      vp.Hdg,
+		// HFP gives the offset from the schedule, negative when late
+		Delay:     -vp.Dl,
+		DoorsOpen: vp.Drst == 1,
+	}
+	if fields[7] != "EOL" {
+		v.NextStop = fields[7]
+	}
+
+	if vp.Tst != "" {
+		t, err := time.Parse(time.RFC3339, vp.Tst)
+		if err != nil {
+			return Vehicle{}, err
+		}
+		v.Time = t
+	}
+
+	return v, nil
+}
+
+// hfpModes maps the API's transport modes to the ones used in HFP topics.
+var hfpModes = map[string]string{
+	"BUS":    "bus",
+	"TRAM":   "tram",
+	"RAIL":   "train",
+	"SUBWAY": "metro",
+	"FERRY":  "ferry",
+}
+
+// vehicleBroker returns the MQTT broker set in config, or HSL's own.
+func vehicleBroker(config Config) string {
+	if config.Vehicles.Broker != "" {
+		return config.Vehicles.Broker
+	}
+
+	return defaultMQTTBroker
+}
+
+// vehicleFilter picks the vehicles to follow. Empty fields match everything.
+type vehicleFilter struct {
+	// Modes are HFP transport modes, e.g. "bus", "tram" or "metro".
+	Modes []string
+	// RouteIDs are routes' GTFS ids without the feed prefix, e.g. "2550", as
+	// in the topic. resolveRouteIDs finds them for short names.
+	RouteIDs []string
+	// Direction is the HFP direction, "1" or "2", and StartTime the
+	// journey's scheduled start, e.g. "14:05", to follow a single journey of
+	// the routes.
+	Direction string
+	StartTime string
+}
+
+// topics returns the MQTT topics to subscribe to. The broker does the
+// filtering, following a route without its mode is still only that route.
+func (f vehicleFilter) topics() []string {
+	modes := []string{}
+	for _, mode := range f.Modes {
+		modes = append(modes, strings.ToLower(mode))
+	}
+	if len(modes) == 0 {
+		modes = []string{"+"}
+	}
+
+	topics := []string{}
+	for _, mode := range modes {
+		if len(f.RouteIDs) == 0 {
+			topics = append(topics, hfpTopicPrefix+mode+"/#")
+			continue
+		}
+		for _, id := range f.RouteIDs {
+			topics = append(topics, hfpTopicPrefix+mode+"/+/+/"+id+"/"+topicLevel(f.Direction)+"/+/"+topicLevel(f.StartTime)+"/#")
+		}
+	}
+
+	return topics
+}
+
+// topicLevel returns s as a topic level, the wildcard if it's empty.
+func topicLevel(s string) string {
+	if s == "" {
+		return "+"
+	}
+
+	return s
+}
+
+func (f vehicleFilter) match(v Vehicle) bool {
+	if len(f.RouteIDs) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(f.RouteIDs, func(id string) bool { return strings.EqualFold(id, v.RouteID) })
+}
+
+// hfpRouteID returns a route's GTFS id as it is in HFP topics, without the
+// feed prefix, e.g. "HSL:2550" gives "2550".
+func hfpRouteID(gtfsID string) string {
+	_, id, found := strings.Cut(gtfsID, ":")
+	if !found {
+		return gtfsID
+	}
+
+	return id
+}
+
+// resolveRouteIDs returns the HFP route ids of the routes with the given
+// short names. Names that aren't a route's short name are taken to be ids
+// already.
+func resolveRouteIDs(apikey string, names []string) ([]string, error) {
+	ids := []string{}
+	for _, name := range names {
+		routes, err := getRoutes(apikey, name)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, route := range routes {
+			if strings.EqualFold(route.ShortName, name) {
+				ids = append(ids, hfpRouteID(route.GtfsID))
+				found = true
+			}
+		}
+		if !found {
+			ids = append(ids, name)
+		}
+	}
+
+	return ids, nil
+}
+
+// watchVehicles connects to the MQTT broker and publishes the positions of
+// the vehicles that match filter on the returned channel until ctx is
+// cancelled. Positions that a slow reader doesn't keep up with are dropped,
+// a newer one follows within seconds. The client reconnects on its own if
+// the connection is lost.
+func watchVehicles(ctx context.Context, broker string, filter vehicleFilter) (<-chan Vehicle, error) {
+	vehicles := make(chan Vehicle, 64)
+
+	var mu sync.Mutex
+	closed := false
+
+	handle := func(_ mqtt.Client, msg mqtt.Message) {
+		v, err := parseHFP(msg.Topic(), msg.Payload())
+		if err != nil || !filter.match(v) {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case vehicles <- v:
+		default:
+		}
+	}
+
+	subscriptions := map[string]byte{}
+	for _, topic := range filter.topics() {
+		subscriptions[topic] = 0
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("hslterm-%v-%v", os.Getpid(), time.Now().UnixNano())).
+		SetAutoReconnect(true).
+		SetConnectTimeout(10 * time.Second).
+		// Subscriptions don't survive a reconnect with a clean session
+		SetOnConnectHandler(func(c mqtt.Client) {
+			c.SubscribeMultiple(subscriptions, handle)
+		})
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("failed to connect to %v: %w", broker, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		client.Disconnect(250)
+
+		mu.Lock()
+		closed = true
+		close(vehicles)
+		mu.Unlock()
+	}()
+
+	return vehicles, nil
+}
+
+// vehiclesCommand implements hslterm vehicles.
+func vehiclesCommand(args []string) {
+	fs := flag.NewFlagSet("vehicles", flag.ExitOnError)
+	apikey, tempApikey := addApikeyFlags(fs)
+	route := fs.String("route", "", "Routes to follow, comma separated")
+	mode := fs.String("mode", "", "Transport modes to follow, comma separated, e.g. bus,tram,metro")
+	broker := fs.String("broker", "", "MQTT broker URL (default "+defaultMQTTBroker+")")
+	asJSON := fs.Bool("json", false, "Print each position as a line of JSON")
+	fs.Parse(args)
+
+	if *broker == "" {
+		*broker = vehicleBroker(mustLoadConfig())
+	}
+
+	filter := vehicleFilter{Modes: splitFlagList(*mode)}
+	routes := splitFlagList(*route)
+	if len(routes) == 0 && len(filter.Modes) == 0 {
+		fmt.Println(redText(tr("give the vehicles to follow with -route or -mode")))
+		os.Exit(1)
+	}
+
+	// The topics have route ids, not the short names people know
+	if len(routes) > 0 {
+		var err error
+		filter.RouteIDs, err = resolveRouteIDs(resolveApikey(*apikey, *tempApikey), routes)
+		if err != nil {
+			fmt.Println(redText("got err " + err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	vehicles, err := watchVehicles(ctx, *broker, filter)
+	if err != nil {
+		fmt.Println(redText(err.Error()))
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for v := range vehicles {
+		if *asJSON {
+			encoder.Encode(v)
+			continue
+		}
+
+		fmt.Printf("%v %v %v → %v  %.5f,%.5f  %.0f km/h  %+ds  next stop %v\n",
+			v.Time.In(hsltime.Helsinki).Format("15:04:05"), bold(v.Route), v.ID, v.Headsign, v.Lat, v.Lon, v.Speed*3.6, v.Delay, v.NextStop)
+	}
+}
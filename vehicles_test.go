@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseHFP(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		want    Vehicle
+		wantErr bool
+	}{
+		{
+			name:    "late bus",
+			topic:   "/hfp/v2/journey/ongoing/vp/bus/0022/00854/2550/1/Itäkeskus(M)/08:16/1130446/4/60;24/19/87/57",
+			payload: `{"VP":{"desi":"550","dir":"1","oper":22,"veh":854,"tst":"2025-06-13T05:23:50.589Z","tsi":1749792230,"spd":8.49,"hdg":97,"lat":60.193896,"long":24.996082,"acc":0.28,"dl":-40,"odo":2219,"drst":0,"oday":"2025-06-13","jrn":380,"line":153,"start":"08:16","loc":"GPS","stop":null,"route":"2550","occu":0}}`,
+			want: Vehicle{
+				ID: "22/854", Mode: "bus", Route: "550", RouteID: "2550", Direction: "1", Headsign: "Itäkeskus(M)",
+				StartTime: "08:16", OperatingDay: "2025-06-13", NextStop: "1130446", Lat: 60.193896, Lon: 24.996082,
+				Speed: 8.49, Heading: 97, Delay: 40, Time: time.Date(2025, 6, 13, 5, 23, 50, 589000000, time.UTC),
+			},
+		},
+		{
+			name:    "early tram with doors open",
+			topic:   "/hfp/v2/journey/ongoing/vp/tram/0040/00421/1004/2/Katajanokka/14:05/1201124/5/60;24/19/86/56",
+			payload: `{"VP":{"desi":"4","dir":"2","oper":40,"veh":421,"tst":"2025-06-13T11:12:00Z","spd":0,"hdg":180,"lat":60.169,"long":24.939,"dl":75,"drst":1,"oday":"2025-06-13","start":"14:05","route":"1004"}}`,
+			want: Vehicle{
+				ID: "40/421", Mode: "tram", Route: "4", RouteID: "1004", Direction: "2", Headsign: "Katajanokka",
+				StartTime: "14:05", OperatingDay: "2025-06-13", NextStop: "1201124", Lat: 60.169, Lon: 24.939,
+				Heading: 180, Delay: -75, DoorsOpen: true, Time: time.Date(2025, 6, 13, 11, 12, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "metro at the end of the line",
+			topic:   "/hfp/v2/journey/ongoing/vp/metro/0050/00102/31M1/1/Vuosaari/23:58/EOL/5/60;25/20/14/22",
+			payload: `{"VP":{"desi":"M1","dir":"1","oper":50,"veh":102,"tst":"2025-06-13T21:30:02Z","spd":0,"hdg":90,"lat":60.2078,"long":25.1443,"dl":0,"drst":1,"oday":"2025-06-13","start":"23:58","route":"31M1"}}`,
+			want: Vehicle{
+				ID: "50/102", Mode: "metro", Route: "M1", RouteID: "31M1", Direction: "1", Headsign: "Vuosaari",
+				StartTime: "23:58", OperatingDay: "2025-06-13", Lat: 60.2078, Lon: 25.1443,
+				Heading: 90, DoorsOpen: true, Time: time.Date(2025, 6, 13, 21, 30, 2, 0, time.UTC),
+			},
+		},
+		{
+			name:    "other event type",
+			topic:   "/hfp/v2/journey/ongoing/dep/bus/0022/00854/2550/1/Itäkeskus(M)/08:16/1130446/4/60;24/19/87/57",
+			payload: `{"DEP":{}}`,
+			wantErr: true,
+		},
+		{
+			name:    "short topic",
+			topic:   "/hfp/v2/journey/ongoing/vp/bus/0022/00854/2550",
+			payload: `{"VP":{}}`,
+			wantErr: true,
+		},
+		{
+			name:    "broken payload",
+			topic:   "/hfp/v2/journey/ongoing/vp/bus/0022/00854/2550/1/Itäkeskus(M)/08:16/1130446/4/60;24/19/87/57",
+			payload: `{"VP":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHFP(tt.topic, []byte(tt.payload))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("got time %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVehicleFilterTopics(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter vehicleFilter
+		want   []string
+	}{
+		{"mode", vehicleFilter{Modes: []string{"TRAM"}}, []string{hfpTopicPrefix + "tram/#"}},
+		{"route", vehicleFilter{RouteIDs: []string{"2550"}}, []string{hfpTopicPrefix + "+/+/+/2550/+/+/+/#"}},
+		{"route and mode", vehicleFilter{Modes: []string{"bus"}, RouteIDs: []string{"2550", "2551"}}, []string{hfpTopicPrefix + "bus/+/+/2550/+/+/+/#", hfpTopicPrefix + "bus/+/+/2551/+/+/+/#"}},
+		{"journey", vehicleFilter{Modes: []string{"bus"}, RouteIDs: []string{"2550"}, Direction: "2", StartTime: "08:16"}, []string{hfpTopicPrefix + "bus/+/+/2550/2/+/08:16/#"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.topics(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}